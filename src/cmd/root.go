@@ -17,20 +17,46 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+	_ "time/tzdata" // the final image has no zoneinfo
 
 	"github.com/nullify005/chat-hvac/pkg/adapter"
 	"github.com/nullify005/chat-hvac/pkg/adapter/console"
 	"github.com/nullify005/chat-hvac/pkg/adapter/slack"
+	"github.com/nullify005/chat-hvac/pkg/alert"
+	"github.com/nullify005/chat-hvac/pkg/audit"
 	"github.com/nullify005/chat-hvac/pkg/config"
 	"github.com/nullify005/chat-hvac/pkg/health"
+	"github.com/nullify005/chat-hvac/pkg/history"
 	"github.com/nullify005/chat-hvac/pkg/hvac"
+	"github.com/nullify005/chat-hvac/pkg/metrics"
 	"github.com/nullify005/chat-hvac/pkg/receiver"
+	"github.com/nullify005/chat-hvac/pkg/scheduler"
 	"github.com/spf13/cobra"
 )
 
+const (
+	// how long intesis may keep failing before the bot reports not ready
+	defaultUpstreamGrace time.Duration = 5 * time.Minute
+	// how long the health server is given to finish serving on shutdown
+	healthShutdownTimeout time.Duration = 5 * time.Second
+
+	schedulesFile   string = "schedules.json"
+	timersFile      string = "timers.json"
+	presetsFile     string = "presets.json"
+	historyFile     string = "history.db"
+	thermostatsFile string = "thermostats.json"
+	awayFile        string = "away.json"
+	auditFile       string = "audit.jsonl"
+)
+
 var (
 	flagsConfig  string
 	flagsAdapter string
@@ -45,20 +71,208 @@ var (
 			if err != nil {
 				logger.Fatalf("unable to read config: %s cause: %v", flagsConfig, err)
 			}
+			m := metrics.New()
+			health := health.New(health.WithLogger(logger), health.WithHandler("/metrics", m.Handler()))
 			if flagsAdapter == "slack" {
-				adapter = slack.New(c.BotToken, c.AppToken, slack.WithLogger(logger))
+				listener := slack.New(c.BotToken, c.AppToken, slack.WithLogger(logger))
+				health.Register("adapter", listener.Connected)
+				adapter = listener
 			} else {
 				adapter = console.New(console.WithLogger(logger))
 			}
-			h := hvac.New(hvac.WithApi(c.Intesis), hvac.WithDevice(c.Device), hvac.WithLogger(logger))
-			r := receiver.New(adapter, receiver.WithLogger(logger), receiver.WithHvac(h))
-			health := health.New(health.WithLogger(logger))
+			opts, err := receiverOptions(c, m, health, logger)
+			if err != nil {
+				logger.Fatalf("invalid config: %s cause: %v", flagsConfig, err)
+			}
+			store, err := openHistory(c, logger)
+			if err != nil {
+				logger.Fatalf("unable to open history. cause: %v", err)
+			}
+			if store != nil {
+				defer store.Close()
+				opts = append(opts, receiver.WithHistory(store))
+			}
+			r := receiver.New(adapter, opts...)
 			health.Run()
-			r.Receive()
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			r.Receive(ctx)
+			ctx, cancel := context.WithTimeout(context.Background(), healthShutdownTimeout)
+			defer cancel()
+			if err := health.Shutdown(ctx); err != nil {
+				logger.Printf("unable to shutdown the health server cleanly. cause: %v", err)
+			}
+			logger.Print("bye")
 		},
 	}
 )
 
+// translate the config into options for the receiver, with a hvac client per device
+func receiverOptions(c *config.Config, m *metrics.Metrics, hc *health.Health, logger *log.Logger) ([]receiver.ReceiverOption, error) {
+	opts := []receiver.ReceiverOption{receiver.WithLogger(logger), receiver.WithChannel(c.Channel), receiver.WithMetrics(m)}
+	loc, err := location(c)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, receiver.WithScheduler(newScheduler(c, loc, logger)), receiver.WithLocation(loc))
+	if c.StateDir != "" {
+		opts = append(opts,
+			receiver.WithPresetPath(filepath.Join(c.StateDir, presetsFile)),
+			receiver.WithThermostatPath(filepath.Join(c.StateDir, thermostatsFile)),
+			receiver.WithAwayPath(filepath.Join(c.StateDir, awayFile)),
+		)
+	}
+	for name, settings := range c.Presets {
+		opts = append(opts, receiver.WithPreset(name, settings))
+	}
+	if path := auditPath(c); path != "" {
+		opts = append(opts, receiver.WithAudit(audit.New(path)))
+	}
+	awayOpts, err := awayOptions(c)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, awayOpts...)
+	for name, g := range c.Auth {
+		role, err := receiver.ParseRole(name)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, receiver.WithGrant(role, receiver.Grant{Users: g.Users, Groups: g.Groups, Channels: g.Channels}))
+	}
+	if c.Confirm != nil {
+		opts = append(opts, receiver.WithConfirm(c.Confirm...))
+	}
+	if c.PollInterval > 0 {
+		opts = append(opts,
+			receiver.WithPolling(c.PollInterval),
+			receiver.WithAlerter(newAlerter(c, logger)),
+			receiver.WithThermostat(
+				hvac.WithHysteresis(c.Thermostat.Hysteresis),
+				hvac.WithMinRuntime(c.Thermostat.MinOnTime, c.Thermostat.MinOffTime),
+				hvac.WithMaxCycles(c.Thermostat.MaxCyclesPerHour),
+			),
+		)
+	}
+	if c.ShutdownTimeout > 0 {
+		opts = append(opts, receiver.WithDrainTimeout(c.ShutdownTimeout))
+	}
+	if c.OfflineMessage != "" {
+		opts = append(opts, receiver.WithOfflineMessage(c.OfflineMessage))
+	}
+	if c.ConfirmTimeout > 0 {
+		opts = append(opts, receiver.WithConfirmTimeout(c.ConfirmTimeout))
+	}
+	if len(c.Devices) == 0 {
+		// a single device is named default by the receiver
+		h := hvac.New(append(hvacOptions(c, m, "default", logger), hvac.WithDevice(c.Device))...)
+		hc.Register("upstream", upstreamCheck(c, h))
+		return append(opts, receiver.WithHvac(h)), nil
+	}
+	for name, id := range c.Devices {
+		h := hvac.New(append(hvacOptions(c, m, name, logger), hvac.WithDevice(id))...)
+		hc.Register("upstream/"+name, upstreamCheck(c, h))
+		opts = append(opts, receiver.WithDevice(name, h))
+	}
+	for channel, name := range c.ChannelDevices {
+		opts = append(opts, receiver.WithChannelDevice(channel, name))
+	}
+	return opts, nil
+}
+
+// where changes are audited, empty when auditing is disabled
+func auditPath(c *config.Config) string {
+	if c.AuditLog != "" || c.StateDir == "" {
+		return c.AuditLog
+	}
+	return filepath.Join(c.StateDir, auditFile)
+}
+
+// the profile applied while away & when the house is preconditioned
+func awayOptions(c *config.Config) ([]receiver.ReceiverOption, error) {
+	opts := []receiver.ReceiverOption{receiver.WithPrecondition(c.Away.Precondition)}
+	if c.Away.Profile != nil {
+		opts = append(opts, receiver.WithAwayProfile(c.Away.Profile))
+	}
+	if c.Away.Band != "" {
+		b, err := receiver.ParseBand(c.Away.Band)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, receiver.WithAwayBand(b))
+	}
+	return opts, nil
+}
+
+// the configured time zone, defaulting to local
+func location(c *config.Config) (*time.Location, error) {
+	if c.TimeZone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(c.TimeZone)
+}
+
+// a scheduler persisting to the state directory within the time zone
+func newScheduler(c *config.Config, loc *time.Location, logger *log.Logger) *scheduler.Scheduler {
+	opts := []scheduler.SchedulerOption{scheduler.WithLogger(logger), scheduler.WithLocation(loc)}
+	if c.StateDir != "" {
+		opts = append(opts,
+			scheduler.WithPath(filepath.Join(c.StateDir, schedulesFile)),
+			scheduler.WithTimerPath(filepath.Join(c.StateDir, timersFile)),
+		)
+	}
+	return scheduler.New(opts...)
+}
+
+// the history within the state directory, nil unless polling is enabled as
+// there's nothing to record
+func openHistory(c *config.Config, logger *log.Logger) (*history.Store, error) {
+	if c.StateDir == "" || c.PollInterval <= 0 {
+		return nil, nil
+	}
+	return history.Open(filepath.Join(c.StateDir, historyFile), history.WithLogger(logger), history.WithRetention(c.HistoryRetention))
+}
+
+// ready unless calls to intesis for the device have failed for longer than the grace
+func upstreamCheck(c *config.Config, h *hvac.Hvac) health.Check {
+	grace := c.UpstreamGrace
+	if grace <= 0 {
+		grace = defaultUpstreamGrace
+	}
+	return func() error {
+		return h.Healthy(grace)
+	}
+}
+
+// an alerter describing the configured error codes
+func newAlerter(c *config.Config, logger *log.Logger) *alert.Alerter {
+	opts := []alert.AlerterOption{alert.WithLogger(logger), alert.WithErrorCodes(c.ErrorCodes)}
+	if c.AlertReminder > 0 {
+		opts = append(opts, alert.WithReminder(c.AlertReminder))
+	}
+	return alert.New(opts...)
+}
+
+// translate the config into options for the hvac client, unset values retain the defaults
+func hvacOptions(c *config.Config, m *metrics.Metrics, name string, logger *log.Logger) []hvac.HvacOption {
+	opts := []hvac.HvacOption{
+		hvac.WithApi(c.Intesis),
+		hvac.WithLogger(logger),
+		hvac.WithObserver(m.Upstream()),
+		hvac.WithStatusObserver(m.Device(name)),
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, hvac.WithTimeout(c.Timeout))
+	}
+	if c.Retries != nil {
+		opts = append(opts, hvac.WithRetries(*c.Retries))
+	}
+	if c.Backoff > 0 || c.MaxBackoff > 0 {
+		opts = append(opts, hvac.WithBackoff(c.Backoff, c.MaxBackoff))
+	}
+	return opts
+}
+
 func Execute() {
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
 	if err := rootCmd.Execute(); err != nil {
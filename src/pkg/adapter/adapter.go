@@ -6,12 +6,28 @@ type Adapter interface {
 	Say(Message)
 }
 
+// implemented by adapters which can resolve the members of a user group
+type GroupResolver interface {
+	GroupMembers(group string) ([]string, error)
+}
+
+// implemented by adapters which can post files such as charts, adapters
+// without it are sent text instead
+type Uploader interface {
+	Upload(File) error
+}
+
 type Event struct {
 	User      string
 	Message   string
 	Type      string
 	Channel   string
 	Timestamp string
+	Thread    string  // the timestamp of the thread the event was sent within, if any
+	Action    *Action // populated for InteractionEvent
+	// where replies to a SlashCommandEvent are sent, which works in channels
+	// the bot isn't a member of
+	ResponseURL string
 }
 
 type Message struct {
@@ -19,8 +35,48 @@ type Message struct {
 	Channel   string
 	Timestamp string
 	Threaded  bool
+	Panel     *Panel // an interactive control panel, adapters without support only send Text
+	Update    bool   // replace the message at Timestamp instead of posting a new one
+	Ephemeral bool   // only visible to User
+	User      string
+	// ephemeral messages are sent to it instead of the channel when set
+	ResponseURL string
+}
+
+// a file posted to a channel with an optional comment
+type File struct {
+	Name      string
+	Title     string
+	Comment   string
+	Content   []byte
+	Channel   string
+	Timestamp string
+	Threaded  bool
+}
+
+// a request to set a device key to a value from an interactive control
+type Action struct {
+	Device string
+	Key    string
+	Value  string
+}
+
+// the state of a device to render as an interactive control panel
+type Panel struct {
+	Device      string
+	Power       string
+	Mode        string
+	Modes       []string
+	FanSpeed    string
+	FanSpeeds   []string
+	Setpoint    float64
+	Temperature float64
+	Notice      string // the outcome of the last action
 }
 
 const (
-	AppMentionEvent string = "app_mention"
+	AppMentionEvent    string = "app_mention"
+	InteractionEvent   string = "interaction"
+	SlashCommandEvent  string = "slash_command"
+	DirectMessageEvent string = "direct_message"
 )
@@ -47,11 +47,15 @@ func (l *Listener) Listen(output chan adapter.Event) {
 				close(output)
 				break listener
 			default:
-				scanner.Scan()
-				if err := scanner.Err(); err != nil {
-					l.logger.Printf("error reading from stdin. cause: %v", err)
-					l.Shutdown()
-					continue
+				if !scanner.Scan() {
+					if err := scanner.Err(); err != nil {
+						l.logger.Printf("error reading from stdin. cause: %v", err)
+					}
+					// nothing more will be read, so wait for the shutdown
+					<-l.shutdown
+					log.Print("received close, shutting down")
+					close(output)
+					break listener
 				}
 				evt := &adapter.Event{
 					User:      os.Getenv("USER"),
@@ -74,5 +78,8 @@ func (l *Listener) Say(m adapter.Message) {
 
 func (l *Listener) Shutdown() {
 	l.logger.Print("shutting down")
-	l.shutdown <- true
+	select {
+	case l.shutdown <- true:
+	default:
+	}
 }
@@ -0,0 +1,92 @@
+package slack
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nullify005/chat-hvac/pkg/adapter"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// action ids are of the form hvac/<device>/<key>[/<suffix>]
+	actionPrefix   string  = "hvac"
+	actionSep      string  = "/"
+	setpointStep   float64 = 1
+	panelBlockID   string  = "hvac_panel"
+	controlBlockID string  = "hvac_controls"
+)
+
+// render a Panel as Block Kit blocks
+func panelBlocks(p *adapter.Panel) []slack.Block {
+	summary := fmt.Sprintf("*%s* power: *%s* mode: *%s* setpoint: *%.1f°C* room: *%.1f°C* fan: *%s*",
+		p.Device, p.Power, p.Mode, p.Setpoint, p.Temperature, p.FanSpeed)
+	if p.Notice != "" {
+		summary += "\n" + p.Notice
+	}
+	power := "on"
+	style := slack.StylePrimary
+	if strings.EqualFold(p.Power, "on") {
+		power = "off"
+		style = slack.StyleDanger
+	}
+	powerButton := slack.NewButtonBlockElement(actionID(p.Device, "power"), power, plainText("Power "+power))
+	powerButton.Style = style
+	elements := []slack.BlockElement{
+		powerButton,
+		slack.NewButtonBlockElement(actionID(p.Device, "setpoint", "down"), formatSetpoint(p.Setpoint-setpointStep), plainText("−")),
+		slack.NewButtonBlockElement(actionID(p.Device, "setpoint", "up"), formatSetpoint(p.Setpoint+setpointStep), plainText("+")),
+	}
+	if len(p.Modes) > 0 {
+		elements = append(elements, selectElement(actionID(p.Device, "mode"), "Mode", p.Mode, p.Modes))
+	}
+	if len(p.FanSpeeds) > 0 {
+		elements = append(elements, selectElement(actionID(p.Device, "fan_speed"), "Fan", p.FanSpeed, p.FanSpeeds))
+	}
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, summary, false, false), nil, nil, slack.SectionBlockOptionBlockID(panelBlockID)),
+		slack.NewActionBlock(controlBlockID, elements...),
+	}
+}
+
+// a static select with the current value as the initial option
+func selectElement(id, placeholder, current string, values []string) *slack.SelectBlockElement {
+	options := []*slack.OptionBlockObject{}
+	var initial *slack.OptionBlockObject
+	for _, v := range values {
+		o := slack.NewOptionBlockObject(v, plainText(v), nil)
+		if strings.EqualFold(v, current) {
+			initial = o
+		}
+		options = append(options, o)
+	}
+	s := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, plainText(placeholder), id, options...)
+	s.InitialOption = initial
+	return s
+}
+
+// translate a block action from a panel into an adapter Action
+func panelAction(a *slack.BlockAction) (*adapter.Action, bool) {
+	parts := strings.Split(a.ActionID, actionSep)
+	if len(parts) < 3 || parts[0] != actionPrefix {
+		return nil, false
+	}
+	value := a.Value
+	if a.Type == slack.ActionType(slack.OptTypeStatic) {
+		value = a.SelectedOption.Value
+	}
+	return &adapter.Action{Device: parts[1], Key: parts[2], Value: value}, true
+}
+
+func actionID(parts ...string) string {
+	return strings.Join(append([]string{actionPrefix}, parts...), actionSep)
+}
+
+func plainText(t string) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.PlainTextType, t, false, false)
+}
+
+func formatSetpoint(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
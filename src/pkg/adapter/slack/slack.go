@@ -1,9 +1,13 @@
 package slack
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"sync"
 
 	"github.com/nullify005/chat-hvac/pkg/adapter"
 	"github.com/slack-go/slack"
@@ -11,12 +15,28 @@ import (
 	"github.com/slack-go/slack/socketmode"
 )
 
+const (
+	// the slash command registered within the slack app manifest
+	slashCommand string = "/hvac"
+	// the channel type of a message.im event
+	directMessageChannel string = "im"
+)
+
 type Listener struct {
-	shutdown chan bool
-	logger   *log.Logger
-	client   *slack.Client
-	socket   *socketmode.Client
-	output   chan adapter.Event
+	cancel context.CancelFunc
+	logger *log.Logger
+	client *slack.Client
+	socket *socketmode.Client
+	output chan adapter.Event
+	// shared with the mirror so that the middleware can track the socket
+	connection *connection
+}
+
+// the state of the socketmode connection
+type connection struct {
+	mu        sync.Mutex
+	connected bool
+	err       error
 }
 
 var listener Listener // a mirror of the Listener struct so that we can use the vars within
@@ -31,8 +51,9 @@ func WithLogger(l *log.Logger) ListenerOption {
 
 func New(botToken, appToken string, opts ...ListenerOption) *Listener {
 	l := &Listener{
-		logger:   log.New(os.Stdout, "SlackListener: ", log.Ldate|log.Ltime|log.Lshortfile),
-		shutdown: make(chan bool, 1),
+		logger:     log.New(os.Stdout, "SlackListener: ", log.Ldate|log.Ltime|log.Lshortfile),
+		cancel:     func() {},
+		connection: &connection{err: errors.New("not connected yet")},
 	}
 	for _, opt := range opts {
 		opt(l)
@@ -52,48 +73,174 @@ func New(botToken, appToken string, opts ...ListenerOption) *Listener {
 	return l
 }
 
+// the middleware for each socketmode event type
+var middleware = map[socketmode.EventType]socketmode.SocketmodeHandlerFunc{
+	socketmode.EventTypeConnecting:      middlewareConnecting,
+	socketmode.EventTypeConnectionError: middlewareConnectionError,
+	socketmode.EventTypeConnected:       middlewareConnected,
+	socketmode.EventTypeInvalidAuth:     middlewareInvalidAuth,
+}
+
+// the middleware for each events api event type
+var eventsMiddleware = map[slackevents.EventsAPIType]socketmode.SocketmodeHandlerFunc{
+	slackevents.AppMention: middlewareAppMentionEvent,
+	slackevents.Message:    middlewareMessageEvent,
+}
+
+// connect to slack & forward events to output until Shutdown. the events are
+// dispatched here rather than by socketmode.SocketmodeHandler as its event
+// loop can't be stopped
 func (l *Listener) Listen(output chan adapter.Event) {
 	listener.output = output
-	handler := socketmode.NewSocketmodeHandler(l.socket)
-	handler.Handle(socketmode.EventTypeConnecting, middlewareConnecting)
-	handler.Handle(socketmode.EventTypeConnectionError, middlewareConnectionError)
-	handler.Handle(socketmode.EventTypeConnected, middlewareConnected)
-	handler.HandleEvents(slackevents.AppMention, middlewareAppMentionEvent)
+	ctx, cancel := context.WithCancel(context.Background())
+	l.cancel = cancel
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case evt := <-l.socket.Events:
+				l.dispatch(evt)
+			}
+		}
+	}()
 	go func() {
-		l.logger.Fatal(handler.RunEventLoop())
+		err := l.socket.RunContext(ctx)
+		if ctx.Err() != nil {
+			l.logger.Print("socketmode stopped")
+			return
+		}
+		l.logger.Fatal(err)
 	}()
 }
 
+// run the middleware registered for the event
+func (l *Listener) dispatch(evt socketmode.Event) {
+	var f socketmode.SocketmodeHandlerFunc
+	switch evt.Type {
+	case socketmode.EventTypeEventsAPI:
+		if e, ok := evt.Data.(slackevents.EventsAPIEvent); ok {
+			f = eventsMiddleware[slackevents.EventsAPIType(e.InnerEvent.Type)]
+		}
+	case socketmode.EventTypeInteractive:
+		if i, ok := evt.Data.(slack.InteractionCallback); ok && i.Type == slack.InteractionTypeBlockActions {
+			f = middlewareBlockActions
+		}
+	case socketmode.EventTypeSlashCommand:
+		if c, ok := evt.Data.(slack.SlashCommand); ok && c.Command == slashCommand {
+			f = middlewareSlashCommand
+		}
+	default:
+		f = middleware[evt.Type]
+	}
+	if f == nil {
+		l.logger.Printf("ignored socketmode event: %s", evt.Type)
+		// slack retries events which aren't acked, eg. other slash commands
+		if evt.Request != nil {
+			l.socket.Ack(*evt.Request)
+		}
+		return
+	}
+	go f(&evt, l.socket)
+}
+
 func (l *Listener) Say(m adapter.Message) {
 	var err error
-	if m.Threaded {
-		_, _, err = l.client.PostMessage(m.Channel,
-			slack.MsgOptionText(m.Text, false),
-			slack.MsgOptionTS(fmt.Sprint(m.Timestamp)),
-		)
-	} else {
-		_, _, err = l.client.PostMessage(m.Channel, slack.MsgOptionText(m.Text, false))
+	opts := []slack.MsgOption{slack.MsgOptionText(m.Text, false)}
+	if m.Panel != nil {
+		opts = append(opts, slack.MsgOptionBlocks(panelBlocks(m.Panel)...))
+	}
+	switch {
+	case m.Update:
+		_, _, _, err = l.client.UpdateMessage(m.Channel, m.Timestamp, opts...)
+	case m.ResponseURL != "":
+		// replies to slash commands & interactions go back through their
+		// response url, which works even where the bot isn't a member
+		kind := slack.ResponseTypeInChannel
+		if m.Ephemeral {
+			kind = slack.ResponseTypeEphemeral
+		}
+		_, _, err = l.client.PostMessage(m.Channel, append(opts, slack.MsgOptionResponseURL(m.ResponseURL, kind))...)
+	case m.Ephemeral:
+		_, err = l.client.PostEphemeral(m.Channel, m.User, opts...)
+	case m.Threaded:
+		_, _, err = l.client.PostMessage(m.Channel, append(opts, slack.MsgOptionTS(fmt.Sprint(m.Timestamp)))...)
+	default:
+		_, _, err = l.client.PostMessage(m.Channel, opts...)
 	}
 	if err != nil {
 		l.logger.Printf("unable to post message. cause: %v", err)
 	}
 }
 
+// post a file to the channel through the files v2 api, which uploads to an
+// external url & then shares the file to the channel
+func (l *Listener) Upload(f adapter.File) error {
+	params := slack.UploadFileV2Parameters{
+		Reader:         bytes.NewReader(f.Content),
+		FileSize:       len(f.Content),
+		Filename:       f.Name,
+		Title:          f.Title,
+		InitialComment: f.Comment,
+		Channel:        f.Channel,
+	}
+	if f.Threaded {
+		params.ThreadTimestamp = f.Timestamp
+	}
+	_, err := l.client.UploadFileV2(params)
+	return err
+}
+
+// the user ids which are members of the user group
+func (l *Listener) GroupMembers(group string) ([]string, error) {
+	return l.client.GetUserGroupMembers(group)
+}
+
+// disconnect from slack, no further events are forwarded
 func (l *Listener) Shutdown() {
 	l.logger.Print("shutting down")
-	l.shutdown <- true
+	l.cancel()
+}
+
+// nil when the socketmode connection is up, otherwise why it isn't
+func (l *Listener) Connected() error {
+	l.connection.mu.Lock()
+	defer l.connection.mu.Unlock()
+	if l.connection.connected {
+		return nil
+	}
+	return l.connection.err
+}
+
+// record the state of the socketmode connection
+func (c *connection) set(connected bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected, c.err = connected, err
 }
 
 func middlewareConnecting(evt *socketmode.Event, client *socketmode.Client) {
 	listener.logger.Print("socketmode connectng")
+	listener.connection.set(false, errors.New("connecting"))
 }
 
 func middlewareConnectionError(evt *socketmode.Event, client *socketmode.Client) {
 	listener.logger.Print("socketmode connection error")
+	err := errors.New("connection error")
+	if e, ok := evt.Data.(*slack.ConnectionErrorEvent); ok && e.ErrorObj != nil {
+		err = fmt.Errorf("connection error: %w", e.ErrorObj)
+	}
+	listener.connection.set(false, err)
 }
 
 func middlewareConnected(evt *socketmode.Event, client *socketmode.Client) {
 	listener.logger.Print("socketmode connected")
+	listener.connection.set(true, nil)
+}
+
+func middlewareInvalidAuth(evt *socketmode.Event, client *socketmode.Client) {
+	listener.logger.Print("socketmode invalid auth")
+	listener.connection.set(false, errors.New("invalid auth, check the app & bot tokens"))
 }
 
 func middlewareAppMentionEvent(evt *socketmode.Event, client *socketmode.Client) {
@@ -117,6 +264,88 @@ func middlewareAppMentionEvent(evt *socketmode.Event, client *socketmode.Client)
 		Type:      adapter.AppMentionEvent,
 		Channel:   ev.Channel,
 		Timestamp: ev.EventTimeStamp,
+		Thread:    ev.ThreadTimeStamp,
+	}
+	listener.output <- *event
+}
+
+func middlewareMessageEvent(evt *socketmode.Event, client *socketmode.Client) {
+	listener.logger.Print("socketmode MessageEvent")
+	eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
+	if !ok {
+		listener.logger.Printf("ignored event: %+v", evt)
+		return
+	}
+
+	client.Ack(*evt.Request)
+
+	ev, ok := eventsAPIEvent.InnerEvent.Data.(*slackevents.MessageEvent)
+	if !ok {
+		listener.logger.Printf("ignored event: %+v", ev)
+		return
+	}
+	// only direct messages from people, which excludes our own replies & edits
+	if ev.ChannelType != directMessageChannel || ev.BotID != "" || ev.SubType != "" {
+		return
+	}
+	event := &adapter.Event{
+		User:      ev.User,
+		Message:   ev.Text,
+		Type:      adapter.DirectMessageEvent,
+		Channel:   ev.Channel,
+		Timestamp: ev.EventTimeStamp,
+		Thread:    ev.ThreadTimeStamp,
+	}
+	listener.output <- *event
+}
+
+func middlewareBlockActions(evt *socketmode.Event, client *socketmode.Client) {
+	listener.logger.Print("socketmode BlockActions")
+	callback, ok := evt.Data.(slack.InteractionCallback)
+	if !ok {
+		listener.logger.Printf("ignored event: %+v", evt)
+		return
+	}
+
+	client.Ack(*evt.Request)
+
+	for _, a := range callback.ActionCallback.BlockActions {
+		action, ok := panelAction(a)
+		if !ok {
+			listener.logger.Printf("ignored block action: %+v", a)
+			continue
+		}
+		event := &adapter.Event{
+			User:        callback.User.ID,
+			Message:     fmt.Sprintf("set %s %s %s", action.Device, action.Key, action.Value),
+			Type:        adapter.InteractionEvent,
+			Channel:     callback.Container.ChannelID,
+			Timestamp:   callback.Container.MessageTs,
+			ResponseURL: callback.ResponseURL,
+			Action:      action,
+		}
+		listener.output <- *event
+	}
+}
+
+func middlewareSlashCommand(evt *socketmode.Event, client *socketmode.Client) {
+	listener.logger.Print("socketmode SlashCommand")
+	cmd, ok := evt.Data.(slack.SlashCommand)
+	if !ok {
+		listener.logger.Printf("ignored event: %+v", evt)
+		return
+	}
+
+	client.Ack(*evt.Request)
+
+	// the command takes the place of the leading mention, which the receiver
+	// strips when parsing the text
+	event := &adapter.Event{
+		User:        cmd.UserID,
+		Message:     cmd.Command + " " + cmd.Text,
+		Type:        adapter.SlashCommandEvent,
+		Channel:     cmd.ChannelID,
+		ResponseURL: cmd.ResponseURL,
 	}
 	listener.output <- *event
 }
@@ -0,0 +1,224 @@
+package alert
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nullify005/chat-hvac/pkg/hvac"
+)
+
+const defaultReminder time.Duration = 4 * time.Hour
+
+// the condition an alert was raised for
+type Kind string
+
+const (
+	Fault  Kind = "fault"
+	Filter Kind = "filter"
+)
+
+// where an alert is in its lifecycle when the notifier is called
+type State string
+
+const (
+	Raised   State = "raised"
+	Reminder State = "reminder"
+	Resolved State = "resolved"
+)
+
+// an active condition on a device, it remains active until the condition
+// clears. acknowledging it stops the reminders
+type Alert struct {
+	ID       string
+	Device   string
+	Kind     Kind
+	Code     int
+	Address  int
+	Detail   string
+	Raised   time.Time
+	Notified time.Time
+	Acked    bool
+	AckedBy  string
+}
+
+// called when an alert is raised, is due a reminder or resolves
+type Notifier func(a Alert, s State)
+
+// raises alerts from the polled status of each device, deduplicating them
+// for as long as their condition persists
+type Alerter struct {
+	logger   *log.Logger
+	codes    map[int]string
+	reminder time.Duration
+	notify   Notifier
+	mu       sync.Mutex
+	active   map[string]*Alert // keyed by device, kind & code
+	lastID   int
+}
+
+type AlerterOption func(a *Alerter)
+
+func WithLogger(l *log.Logger) AlerterOption {
+	return func(a *Alerter) {
+		a.logger = l
+	}
+}
+
+// descriptions of error codes, adding to or replacing the known codes
+func WithErrorCodes(codes map[int]string) AlerterOption {
+	return func(a *Alerter) {
+		for code, detail := range codes {
+			a.codes[code] = detail
+		}
+	}
+}
+
+// how often an unacknowledged alert is repeated, zero disables reminders
+func WithReminder(d time.Duration) AlerterOption {
+	return func(a *Alerter) {
+		a.reminder = d
+	}
+}
+
+func New(opts ...AlerterOption) *Alerter {
+	a := &Alerter{
+		logger:   log.New(os.Stdout, "Alerter: ", log.Ldate|log.Ltime|log.Lshortfile),
+		codes:    map[int]string{},
+		reminder: defaultReminder,
+		active:   map[string]*Alert{},
+	}
+	for code, detail := range knownCodes {
+		a.codes[code] = detail
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// start notifying of alerts as they're raised, remind & resolve
+func (a *Alerter) Start(notify Notifier) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.notify = notify
+}
+
+// the subscriber which checks each polled status of the device
+func (a *Alerter) Subscriber(device string) hvac.Subscriber {
+	return func(e hvac.Event) {
+		if e.Type == hvac.StatusPolled {
+			a.Check(device, e.Status, e.Time)
+		}
+	}
+}
+
+// raise, remind or resolve the alerts of a device from its status
+func (a *Alerter) Check(device string, status *hvac.HVACStatus, now time.Time) {
+	conditions := map[string]Alert{}
+	if code := status.Status.ErrorCode; code != 0 || status.Status.AlarmStatus != 0 {
+		c := Alert{Device: device, Kind: Fault, Code: code, Address: status.Status.ErrorAddress, Detail: a.describe(code)}
+		conditions[key(c)] = c
+	}
+	if status.Status.FilterClean != 0 {
+		c := Alert{Device: device, Kind: Filter, Detail: "the filter needs cleaning"}
+		conditions[key(c)] = c
+	}
+	type notice struct {
+		alert Alert
+		state State
+	}
+	notices := []notice{}
+	a.mu.Lock()
+	for k, existing := range a.active {
+		if existing.Device != device {
+			continue
+		}
+		if _, ok := conditions[k]; !ok {
+			delete(a.active, k)
+			notices = append(notices, notice{*existing, Resolved})
+			continue
+		}
+		if !existing.Acked && a.reminder > 0 && now.Sub(existing.Notified) >= a.reminder {
+			existing.Notified = now
+			notices = append(notices, notice{*existing, Reminder})
+		}
+	}
+	for k, c := range conditions {
+		if _, ok := a.active[k]; ok {
+			continue
+		}
+		a.lastID++
+		raised := c
+		raised.ID = strconv.Itoa(a.lastID)
+		raised.Raised, raised.Notified = now, now
+		a.active[k] = &raised
+		notices = append(notices, notice{raised, Raised})
+	}
+	notify := a.notify
+	a.mu.Unlock()
+	for _, n := range notices {
+		a.logger.Printf("alert: %s %s device: %s kind: %s code: %d", n.alert.ID, n.state, n.alert.Device, n.alert.Kind, n.alert.Code)
+		if notify != nil {
+			notify(n.alert, n.state)
+		}
+	}
+}
+
+// acknowledge the alert with id, or every active alert when id is empty
+func (a *Alerter) Ack(id, user string) ([]Alert, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	acked := []Alert{}
+	for _, al := range a.active {
+		if (id == "" || al.ID == id) && !al.Acked {
+			al.Acked, al.AckedBy = true, user
+			acked = append(acked, *al)
+		}
+	}
+	if id != "" && len(acked) == 0 {
+		return nil, fmt.Errorf("no unacknowledged alert with id: %s", id)
+	}
+	sortAlerts(acked)
+	return acked, nil
+}
+
+// every active alert ordered by id
+func (a *Alerter) Active() []Alert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	list := []Alert{}
+	for _, al := range a.active {
+		list = append(list, *al)
+	}
+	sortAlerts(list)
+	return list
+}
+
+// the description of an error code
+func (a *Alerter) describe(code int) string {
+	if code == 0 {
+		return "the unit raised an alarm"
+	}
+	if detail, ok := a.codes[code]; ok {
+		return detail
+	}
+	return "unknown error, check the unit's manual"
+}
+
+// an alert is unique per device, kind & error code
+func key(a Alert) string {
+	return fmt.Sprintf("%s/%s/%d", a.Device, a.Kind, a.Code)
+}
+
+func sortAlerts(list []Alert) {
+	sort.Slice(list, func(i, j int) bool {
+		a, _ := strconv.Atoi(list[i].ID)
+		b, _ := strconv.Atoi(list[j].ID)
+		return a < b
+	})
+}
@@ -0,0 +1,26 @@
+package alert
+
+// descriptions of the error codes commonly reported through intesishome by
+// mitsubishi electric units, config may add to or replace these
+var knownCodes = map[int]string{
+	1102: "discharge temperature too high",
+	1300: "low pressure fault",
+	1302: "high pressure fault",
+	1500: "refrigerant overcharge",
+	1501: "refrigerant shortage",
+	2500: "drain water leakage",
+	2502: "drain pump fault",
+	2503: "drain sensor fault",
+	4030: "serial communication fault between the indoor & outdoor units",
+	4100: "compressor overcurrent interruption",
+	4220: "inverter bus voltage fault",
+	4230: "heat sink overheat protection",
+	5101: "return air thermistor fault",
+	5102: "indoor pipe thermistor fault",
+	5105: "outdoor pipe thermistor fault",
+	6600: "duplicate unit address",
+	6602: "transmission processor hardware fault",
+	6607: "no acknowledgement from the unit",
+	6608: "no response from the unit",
+	6831: "remote controller communication fault",
+}
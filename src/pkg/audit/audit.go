@@ -0,0 +1,168 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nullify005/chat-hvac/pkg/hvac"
+)
+
+const (
+	// the command of changes made outside of chat, eg. by a remote
+	External string = "external"
+	// the command of changes made by the thermostat
+	Thermostat string = "thermostat"
+)
+
+// a command, or a change made outside of chat, & what it did to a device
+type Record struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user,omitempty"`
+	Channel string    `json:"channel,omitempty"`
+	Command Command   `json:"command"`
+	Device  string    `json:"device,omitempty"`
+	Changes []Change  `json:"changes,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// the command as parsed, or what made the change eg. a schedule
+type Command struct {
+	Verb   string            `json:"verb"`
+	Args   []string          `json:"args,omitempty"`
+	Params map[string]string `json:"params,omitempty"`
+	Raw    string            `json:"raw"`
+}
+
+// a key of a device before & after a command along with the upstream result.
+// Before is what the device reported when it was set & After is the value
+// which was set
+type Change struct {
+	Key     string `json:"key"`
+	Before  string `json:"before"`
+	After   string `json:"after"`
+	Outcome string `json:"outcome"`
+	Result  string `json:"result,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// appends records as json lines to a file
+type Log struct {
+	mu   sync.Mutex
+	path string
+}
+
+// a log appending to the file at path, which is created when first written
+func New(path string) *Log {
+	return &Log{path: path}
+}
+
+// the audit changes of the outcome of hvac.Apply
+func Changes(changes []hvac.Change) []Change {
+	list := []Change{}
+	for _, c := range changes {
+		a := Change{Key: c.Key, Before: c.Previous, After: c.Value, Outcome: c.Outcome.String()}
+		if c.Result != nil {
+			a.Key, a.Result = c.Result.Param, c.Result.Response
+		}
+		if c.Err != nil {
+			a.Error = c.Err.Error()
+		}
+		list = append(list, a)
+	}
+	return list
+}
+
+// the audit change of a single hvac.Set
+func SetChange(key, value string, res *hvac.SetResult, err error) Change {
+	c := Change{Key: key, After: value, Outcome: hvac.Applied.String()}
+	if res != nil {
+		c.Key, c.Before, c.Result = res.Param, res.Previous, res.Response
+	}
+	if err != nil {
+		c.Outcome, c.Error = hvac.Failed.String(), err.Error()
+	}
+	return c
+}
+
+// whether key was changed, even if it was later rolled back
+func (r Record) Changed(key string) bool {
+	for _, c := range r.Changes {
+		if c.Key != key {
+			continue
+		}
+		switch c.Outcome {
+		case hvac.Applied.String(), hvac.RolledBack.String(), hvac.RollbackFailed.String():
+			return true
+		}
+	}
+	return false
+}
+
+// append the record
+func (l *Log) Write(r Record) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o750); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(body, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// the most recent n records matching fn, newest first. a nil fn matches all
+func (l *Log) Recent(n int, fn func(Record) bool) ([]Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return []Record{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// keep the last n matches as the file is read oldest first
+	matches := []Record{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("unable to decode %s line %d cause: %w", l.path, line, err)
+		}
+		if fn != nil && !fn(r) {
+			continue
+		}
+		matches = append(matches, r)
+		if len(matches) > n {
+			matches = matches[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+	return matches, nil
+}
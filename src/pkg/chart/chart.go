@@ -0,0 +1,282 @@
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/nullify005/chat-hvac/pkg/history"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	width  int = 800
+	height int = 400
+	// the plot area within the image, leaving room for the labels
+	left   int = 50
+	right  int = width - 20
+	top    int = 40
+	bottom int = height - 30
+	// consecutive samples further apart than this aren't joined
+	maxGap time.Duration = 45 * time.Minute
+)
+
+var (
+	background  = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	grid        = color.RGBA{0xe0, 0xe0, 0xe0, 0xff}
+	off         = color.RGBA{0xf3, 0xf3, 0xf3, 0xff}
+	text        = color.RGBA{0x44, 0x44, 0x44, 0xff}
+	temperature = color.RGBA{0xd9, 0x48, 0x2b, 0xff}
+	setpoint    = color.RGBA{0x2b, 0x6c, 0xd9, 0xff}
+
+	sparks = []rune("▁▂▃▄▅▆▇█")
+	// the intervals between labelled times along the x axis
+	timeSteps = []time.Duration{
+		15 * time.Minute, 30 * time.Minute, time.Hour, 2 * time.Hour, 3 * time.Hour,
+		6 * time.Hour, 12 * time.Hour, 24 * time.Hour, 48 * time.Hour, 7 * 24 * time.Hour,
+	}
+)
+
+// render the room temperature against the setpoint of the samples as a png,
+// periods where the device was off are shaded
+func PNG(title string, samples []history.Sample) ([]byte, error) {
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("there are no samples to chart")
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	p := newPlot(samples)
+
+	// shade the periods the device was off
+	for i, s := range samples {
+		if s.Power || i == len(samples)-1 || samples[i+1].Time.Sub(s.Time) > maxGap {
+			continue
+		}
+		r := image.Rect(p.x(s.Time), top, p.x(samples[i+1].Time), bottom)
+		draw.Draw(img, r, image.NewUniform(off), image.Point{}, draw.Src)
+	}
+
+	// degrees along the y axis
+	for v := math.Ceil(p.lo/p.step) * p.step; v <= p.hi; v += p.step {
+		y := p.y(v)
+		line(img, left, y, right, y, grid)
+		label(img, 8, y+4, fmt.Sprintf("%4.1f", v), text)
+	}
+	// times along the x axis
+	for t := p.firstTick(); !t.After(p.to); t = t.Add(p.tick) {
+		x := p.x(t)
+		line(img, x, top, x, bottom, grid)
+		format := "15:04"
+		if p.tick >= 24*time.Hour {
+			format = "Mon 2"
+		}
+		label(img, x-len(t.Format(format))*7/2, bottom+18, t.Format(format), text)
+	}
+	line(img, left, bottom, right, bottom, text)
+	line(img, left, top, left, bottom, text)
+
+	// the setpoint holds until it's changed so it's drawn as steps
+	p.series(img, samples, history.Setpoint, setpoint, true)
+	p.series(img, samples, history.Temperature, temperature, false)
+
+	label(img, left, 24, title, text)
+	legend(img, right-200, "temperature", temperature)
+	legend(img, right-85, "setpoint", setpoint)
+
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// the unicode sparkline of the field over at most n characters between lo &
+// hi, samples are averaged into equal periods & periods without any are blank
+func Sparkline(samples []history.Sample, f history.Field, n int, lo, hi float64) string {
+	if len(samples) == 0 || n <= 0 {
+		return ""
+	}
+	from, to := samples[0].Time, samples[len(samples)-1].Time
+	span := to.Sub(from)
+	if len(samples) < n {
+		n = len(samples)
+	}
+	sums, counts := make([]float64, n), make([]int, n)
+	for _, s := range samples {
+		i := 0
+		if span > 0 {
+			i = int(float64(s.Time.Sub(from)) / float64(span) * float64(n-1))
+		}
+		sums[i] += f.Value(s)
+		counts[i]++
+	}
+	b := strings.Builder{}
+	for i := range sums {
+		if counts[i] == 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		v := sums[i] / float64(counts[i])
+		level := 0
+		if hi > lo {
+			level = int((v - lo) / (hi - lo) * float64(len(sparks)-1))
+		}
+		if level < 0 {
+			level = 0
+		}
+		if level >= len(sparks) {
+			level = len(sparks) - 1
+		}
+		b.WriteRune(sparks[level])
+	}
+	return b.String()
+}
+
+// the lowest & highest temperature or setpoint of the samples
+func Range(samples []history.Sample) (float64, float64) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, s := range samples {
+		lo = math.Min(lo, math.Min(s.Temperature, s.Setpoint))
+		hi = math.Max(hi, math.Max(s.Temperature, s.Setpoint))
+	}
+	return lo, hi
+}
+
+// the scale of the plot area
+type plot struct {
+	from, to time.Time
+	lo, hi   float64
+	// the degrees between gridlines & the time between labels
+	step float64
+	tick time.Duration
+}
+
+func newPlot(samples []history.Sample) plot {
+	p := plot{from: samples[0].Time, to: samples[len(samples)-1].Time}
+	if !p.to.After(p.from) {
+		p.to = p.from.Add(time.Minute)
+	}
+	lo, hi := Range(samples)
+	p.lo, p.hi = math.Floor(lo-0.5), math.Ceil(hi+0.5)
+	p.step = 0.5
+	for _, step := range []float64{0.5, 1, 2, 5} {
+		p.step = step
+		if (p.hi-p.lo)/step <= 10 {
+			break
+		}
+	}
+	p.tick = timeSteps[len(timeSteps)-1]
+	for _, step := range timeSteps {
+		if p.to.Sub(p.from)/step <= 8 {
+			p.tick = step
+			break
+		}
+	}
+	return p
+}
+
+// the first labelled time, aligned to the tick in the location of the samples
+func (p plot) firstTick() time.Time {
+	t := p.from
+	if p.tick >= 24*time.Hour {
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	} else {
+		_, offset := t.Zone()
+		shift := time.Duration(offset) * time.Second
+		t = t.Add(shift).Truncate(p.tick).Add(-shift)
+	}
+	for t.Before(p.from) {
+		t = t.Add(p.tick)
+	}
+	return t
+}
+
+func (p plot) x(t time.Time) int {
+	return left + int(float64(t.Sub(p.from))/float64(p.to.Sub(p.from))*float64(right-left))
+}
+
+func (p plot) y(v float64) int {
+	return bottom - int((v-p.lo)/(p.hi-p.lo)*float64(bottom-top))
+}
+
+// join the values of the field, breaking the line across gaps in the samples
+func (p plot) series(img *image.RGBA, samples []history.Sample, f history.Field, c color.Color, steps bool) {
+	for i := 1; i < len(samples); i++ {
+		prev, cur := samples[i-1], samples[i]
+		if cur.Time.Sub(prev.Time) > maxGap {
+			continue
+		}
+		x0, y0, x1, y1 := p.x(prev.Time), p.y(f.Value(prev)), p.x(cur.Time), p.y(f.Value(cur))
+		if steps {
+			thick(img, x0, y0, x1, y0, c)
+			thick(img, x1, y0, x1, y1, c)
+			continue
+		}
+		thick(img, x0, y0, x1, y1, c)
+	}
+}
+
+// a line two pixels wide
+func thick(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	line(img, x0, y0, x1, y1, c)
+	line(img, x0, y0+1, x1, y1+1, c)
+}
+
+// bresenham's line between two points
+func line(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		img.Set(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// a short line in the colour of a series followed by its name
+func legend(img *image.RGBA, x int, name string, c color.Color) {
+	thick(img, x, 20, x+12, 20, c)
+	label(img, x+16, 24, name, c)
+}
+
+func label(img *image.RGBA, x, y int, s string, c color.Color) {
+	d := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(s)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
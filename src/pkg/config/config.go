@@ -3,16 +3,87 @@ package config
 import (
 	"os"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	AppToken string `yaml:"appToken"`
-	BotToken string `yaml:"botToken"`
-	Channel  string `yaml:"channel"`
-	Intesis  string `yaml:"intesis"`
-	Device   string `yaml:"device"`
+	AppToken   string        `yaml:"appToken"`
+	BotToken   string        `yaml:"botToken"`
+	Channel    string        `yaml:"channel"`
+	Intesis    string        `yaml:"intesis"`
+	Device     string        `yaml:"device"`
+	Timeout    time.Duration `yaml:"timeout"`    // per http call to intesis, eg. 10s
+	Retries    *int          `yaml:"retries"`    // retries for idempotent calls to intesis, 0 disables
+	Backoff    time.Duration `yaml:"backoff"`    // initial delay between retries, eg. 250ms
+	MaxBackoff time.Duration `yaml:"maxBackoff"` // upper bound of the delay between retries, eg. 5s
+	// how long intesis may keep failing before the bot reports not ready, eg. 5m
+	UpstreamGrace time.Duration `yaml:"upstreamGrace"`
+
+	// how often device status is polled for changes, alerts & the device metrics
+	// eg. 1m. zero disables polling
+	PollInterval time.Duration `yaml:"pollInterval"`
+
+	// descriptions of device error codes, adding to or replacing the known codes
+	ErrorCodes map[int]string `yaml:"errorCodes"`
+	// how often an unacknowledged alert is repeated, eg. 4h. defaults to 4h
+	AlertReminder time.Duration `yaml:"alertReminder"`
+	// how long polled status is kept in the history within stateDir, eg. 720h.
+	// defaults to 30 days, samples older than a day are downsampled to 15m
+	HistoryRetention time.Duration `yaml:"historyRetention"`
+	// tuning of the thermostat, which is enabled per device from chat
+	Thermostat Thermostat `yaml:"thermostat"`
+	// what away mode applies to every device until the return
+	Away Away `yaml:"away"`
+
+	// how long in flight commands are given to finish on shutdown, eg. 20s
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	// posted to the channel when shutting down, eg. "going offline :wave:"
+	OfflineMessage string `yaml:"offlineMessage"`
+
+	// friendly device names to device ids, eg. lounge: "127934703953"
+	Devices map[string]string `yaml:"devices"`
+	// the default device name for commands within a channel id
+	ChannelDevices map[string]string `yaml:"channelDevices"`
+	// roles (viewer|operator|admin) granted to slack users, user groups &
+	// channels. when empty everyone may use every command
+	Auth map[string]Grant `yaml:"auth"`
+	// commands which must be confirmed before they run eg. `set power off`,
+	// defaults to shutdown & set power off. an empty list disables confirmation
+	Confirm        []string      `yaml:"confirm"`
+	ConfirmTimeout time.Duration `yaml:"confirmTimeout"` // eg. 30s
+	// where schedules, timers & other state are persisted, when empty state is lost on restart
+	StateDir string `yaml:"stateDir"`
+	// the json lines file every change is audited to, defaults to audit.jsonl
+	// within stateDir. auditing is disabled when neither is set
+	AuditLog string `yaml:"auditLog"`
+	// named settings applied together, eg. sleep: {mode: cool, setpoint: "24"}
+	Presets map[string]map[string]string `yaml:"presets"`
+	// the IANA time zone of schedules & timers, eg. Australia/Sydney. defaults to local
+	TimeZone string `yaml:"timeZone"`
+}
+
+type Grant struct {
+	Users    []string `yaml:"users"`
+	Groups   []string `yaml:"groups"`
+	Channels []string `yaml:"channels"`
+}
+
+type Thermostat struct {
+	Hysteresis       float64       `yaml:"hysteresis"`       // degrees beyond the band before acting, eg. 0.5
+	MinOnTime        time.Duration `yaml:"minOnTime"`        // eg. 5m
+	MinOffTime       time.Duration `yaml:"minOffTime"`       // eg. 5m
+	MaxCyclesPerHour int           `yaml:"maxCyclesPerHour"` // eg. 4
+}
+
+type Away struct {
+	// settings applied while away, eg. {power: "off"}. defaults to power off unless band is set
+	Profile map[string]string `yaml:"profile"`
+	// keep the house within a band with the thermostat instead, eg. 16-30. requires polling
+	Band string `yaml:"band"`
+	// how long before the return the settings are restored, eg. 2h
+	Precondition time.Duration `yaml:"precondition"`
 }
 
 func New(path string) (*Config, error) {
@@ -23,6 +94,8 @@ func New(path string) (*Config, error) {
 	}
 	d := yaml.NewDecoder(strings.NewReader(string(body)))
 	d.KnownFields(true)
-	err = d.Decode(&c)
+	if err = d.Decode(&c); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
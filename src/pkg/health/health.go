@@ -1,15 +1,38 @@
 package health
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"sync"
 )
 
+// a readiness check, nil when the component is ready otherwise why it isn't
+type Check func() error
+
 type Health struct {
-	logger *log.Logger
-	listen string
+	logger   *log.Logger
+	listen   string
+	handlers map[string]http.Handler
+	mu       sync.Mutex
+	checks   map[string]Check
+	server   *http.Server
+}
+
+// the readiness of a single check
+type result struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// the body of /readyz
+type readiness struct {
+	Status string            `json:"status"`
+	Checks map[string]result `json:"checks"`
 }
 
 type HealthOption func(h *Health)
@@ -26,10 +49,26 @@ func WithListen(l string) HealthOption {
 	}
 }
 
+// serves an additional handler on the health listener, eg. /metrics
+func WithHandler(pattern string, handler http.Handler) HealthOption {
+	return func(h *Health) {
+		h.handlers[pattern] = handler
+	}
+}
+
+// a readiness check which must pass for /readyz to succeed
+func WithCheck(name string, c Check) HealthOption {
+	return func(h *Health) {
+		h.checks[name] = c
+	}
+}
+
 func New(opts ...HealthOption) *Health {
 	h := &Health{
-		logger: log.New(os.Stdout, "Health: ", log.Ldate|log.Ltime|log.Lshortfile),
-		listen: ":8080",
+		logger:   log.New(os.Stdout, "Health: ", log.Ldate|log.Ltime|log.Lshortfile),
+		listen:   ":8080",
+		handlers: map[string]http.Handler{},
+		checks:   map[string]Check{},
 	}
 	for _, opt := range opts {
 		opt(h)
@@ -37,22 +76,70 @@ func New(opts ...HealthOption) *Health {
 	h.logger.Print("setting up health handlers")
 	http.HandleFunc("/", defaultHandler)
 	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/livez", healthHandler)
+	http.HandleFunc("/readyz", h.readyHandler)
+	for pattern, handler := range h.handlers {
+		http.Handle(pattern, handler)
+	}
+	h.server = &http.Server{Addr: h.listen}
 	return h
 }
 
+// register a readiness check, replacing any with the same name
+func (h *Health) Register(name string, c Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = c
+}
+
 func (h *Health) Run() {
 	go func() {
 		h.logger.Print("starting health listen and serve")
-		h.logger.Fatal(http.ListenAndServe(h.listen, nil))
+		if err := h.server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			h.logger.Fatal(err)
+		}
 	}()
 }
 
+// stop serving, waiting for active requests until ctx is done
+func (h *Health) Shutdown(ctx context.Context) error {
+	h.logger.Print("shutting down")
+	return h.server.Shutdown(ctx)
+}
+
 func defaultHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNotImplemented)
 	io.WriteString(w, "not implemented")
 }
 
+// the process is up, used for liveness
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	io.WriteString(w, "ok")
 }
+
+// runs every check & responds with the breakdown, 503 when any fail
+func (h *Health) readyHandler(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	checks := map[string]Check{}
+	for name, c := range h.checks {
+		checks[name] = c
+	}
+	h.mu.Unlock()
+	body := readiness{Status: "ok", Checks: map[string]result{}}
+	code := http.StatusOK
+	for name, check := range checks {
+		if err := check(); err != nil {
+			h.logger.Printf("not ready. check: %s cause: %v", name, err)
+			body.Checks[name] = result{Error: err.Error()}
+			body.Status, code = "unavailable", http.StatusServiceUnavailable
+			continue
+		}
+		body.Checks[name] = result{OK: true}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		h.logger.Printf("unable to encode readiness cause: %v", err)
+	}
+}
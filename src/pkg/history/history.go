@@ -0,0 +1,270 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nullify005/chat-hvac/pkg/hvac"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	defaultRetention time.Duration = 30 * 24 * time.Hour
+	// samples older than this are averaged into one per resolution
+	defaultDownsampleAfter time.Duration = 24 * time.Hour
+	defaultResolution      time.Duration = 15 * time.Minute
+	// how often retention & downsampling are applied
+	compactInterval time.Duration = time.Hour
+)
+
+// the state of a device at a point in time
+type Sample struct {
+	Time        time.Time `json:"time"`
+	Temperature float64   `json:"temperature"`
+	Setpoint    float64   `json:"setpoint"`
+	Power       bool      `json:"power"`
+	Mode        string    `json:"mode"`
+	// the number of polled samples a downsampled sample averages
+	Count int `json:"count,omitempty"`
+}
+
+// the weight of the sample in averages
+func (s Sample) weight() float64 {
+	if s.Count > 1 {
+		return float64(s.Count)
+	}
+	return 1
+}
+
+// persists the polled status of each device to a bolt database, with a
+// bucket per device keyed by the time of each sample
+type Store struct {
+	logger          *log.Logger
+	db              *bolt.DB
+	retention       time.Duration
+	downsampleAfter time.Duration
+	resolution      time.Duration
+}
+
+type StoreOption func(s *Store)
+
+func WithLogger(l *log.Logger) StoreOption {
+	return func(s *Store) {
+		s.logger = l
+	}
+}
+
+// how long samples are kept, defaults to 30 days
+func WithRetention(d time.Duration) StoreOption {
+	return func(s *Store) {
+		if d > 0 {
+			s.retention = d
+		}
+	}
+}
+
+// samples older than after are averaged into one per resolution
+func WithDownsampling(after, resolution time.Duration) StoreOption {
+	return func(s *Store) {
+		if after > 0 {
+			s.downsampleAfter = after
+		}
+		if resolution > 0 {
+			s.resolution = resolution
+		}
+	}
+}
+
+// open or create the database at path
+func Open(path string, opts ...StoreOption) (*Store, error) {
+	s := &Store{
+		logger:          log.New(os.Stdout, "History: ", log.Ldate|log.Ltime|log.Lshortfile),
+		retention:       defaultRetention,
+		downsampleAfter: defaultDownsampleAfter,
+		resolution:      defaultResolution,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open history: %s cause: %w", path, err)
+	}
+	s.db = db
+	return s, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// the sample of a polled status
+func NewSample(status *hvac.HVACStatus, at time.Time) Sample {
+	return Sample{
+		Time:        at,
+		Temperature: status.RoomTemperature(),
+		Setpoint:    status.SetpointTemperature(),
+		Power:       strings.EqualFold(status.Status.Power, "on"),
+		Mode:        status.Status.Mode,
+	}
+}
+
+// the poller subscriber which records each polled status of the device
+func (s *Store) Subscriber(device string) hvac.Subscriber {
+	return func(e hvac.Event) {
+		if e.Type != hvac.StatusPolled {
+			return
+		}
+		if err := s.Record(device, NewSample(e.Status, e.Time)); err != nil {
+			s.logger.Printf("unable to record history. device: %s cause: %v", device, err)
+		}
+	}
+}
+
+// persist a sample of the device
+func (s *Store) Record(device string, sample Sample) error {
+	value, err := json.Marshal(sample)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(device))
+		if err != nil {
+			return err
+		}
+		return b.Put(key(sample.Time), value)
+	})
+}
+
+// the samples of the device within [from, to) in time order
+func (s *Store) Query(device string, from, to time.Time) ([]Sample, error) {
+	samples := []Sample{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(device))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		end := key(to)
+		for k, v := c.Seek(key(from)); k != nil && bytes.Compare(k, end) < 0; k, v = c.Next() {
+			var sample Sample
+			if err := json.Unmarshal(v, &sample); err != nil {
+				return err
+			}
+			samples = append(samples, sample)
+		}
+		return nil
+	})
+	return samples, err
+}
+
+// apply retention & downsampling every hour until ctx is cancelled
+func (s *Store) Run(ctx context.Context) {
+	ticker := time.NewTicker(compactInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.Compact(time.Now()); err != nil {
+			s.logger.Printf("unable to compact history. cause: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// delete samples beyond retention & average those older than the
+// downsampling threshold into one sample per resolution
+func (s *Store) Compact(now time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			expired := key(now.Add(-s.retention))
+			threshold := key(now.Add(-s.downsampleAfter).Truncate(s.resolution))
+			var (
+				window  time.Time
+				pending []Sample
+				keys    [][]byte
+			)
+			flush := func() error {
+				if len(pending) < 2 {
+					return nil
+				}
+				for _, k := range keys {
+					if err := b.Delete(k); err != nil {
+						return err
+					}
+				}
+				value, err := json.Marshal(average(window, pending))
+				if err != nil {
+					return err
+				}
+				return b.Put(key(window), value)
+			}
+			// collect first as a bucket mustn't be modified while iterating
+			type entry struct {
+				k []byte
+				v []byte
+			}
+			entries := []entry{}
+			c := b.Cursor()
+			for k, v := c.First(); k != nil && bytes.Compare(k, threshold) < 0; k, v = c.Next() {
+				entries = append(entries, entry{append([]byte{}, k...), append([]byte{}, v...)})
+			}
+			for _, e := range entries {
+				if bytes.Compare(e.k, expired) < 0 {
+					if err := b.Delete(e.k); err != nil {
+						return err
+					}
+					continue
+				}
+				var sample Sample
+				if err := json.Unmarshal(e.v, &sample); err != nil {
+					return err
+				}
+				if w := sample.Time.Truncate(s.resolution); !w.Equal(window) {
+					if err := flush(); err != nil {
+						return err
+					}
+					window, pending, keys = w, nil, nil
+				}
+				pending = append(pending, sample)
+				keys = append(keys, e.k)
+			}
+			return flush()
+		})
+	})
+}
+
+// the mean temperature & setpoint of the samples with the last power & mode
+func average(at time.Time, samples []Sample) Sample {
+	avg := Sample{Time: at}
+	for _, s := range samples {
+		avg.Temperature += s.Temperature * s.weight()
+		avg.Setpoint += s.Setpoint * s.weight()
+		avg.Count += int(s.weight())
+	}
+	avg.Temperature /= float64(avg.Count)
+	avg.Setpoint /= float64(avg.Count)
+	last := samples[len(samples)-1]
+	avg.Power, avg.Mode = last.Power, last.Mode
+	return avg
+}
+
+// samples are keyed by their big endian unix nano time so that they sort
+func key(t time.Time) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(t.UnixNano()))
+	return k
+}
@@ -0,0 +1,120 @@
+package history
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompact(t *testing.T) {
+	now := time.Date(2026, 1, 31, 12, 7, 0, 0, time.UTC)
+	at := func(day, hour, min, sec int) time.Time {
+		return time.Date(2026, 1, day, hour, min, sec, 0, time.UTC)
+	}
+	sample := func(t time.Time, temperature float64, count int) Sample {
+		return Sample{Time: t, Temperature: temperature, Setpoint: 22, Power: true, Mode: "cool", Count: count}
+	}
+	tests := []struct {
+		name    string
+		samples []Sample
+		want    []Sample
+	}{
+		{
+			name:    "beyond retention",
+			samples: []Sample{sample(at(23, 12, 6, 59), 20, 0), sample(at(24, 12, 7, 0), 21, 0)},
+			want:    []Sample{sample(at(24, 12, 7, 0), 21, 0)},
+		},
+		{
+			name:    "a window is averaged into its start",
+			samples: []Sample{sample(at(30, 10, 1, 0), 20, 0), sample(at(30, 10, 5, 0), 21, 0), sample(at(30, 10, 14, 59), 22, 0)},
+			want:    []Sample{sample(at(30, 10, 0, 0), 21, 3)},
+		},
+		{
+			name:    "a single sample is left as is",
+			samples: []Sample{sample(at(30, 10, 15, 0), 20, 0), sample(at(30, 10, 31, 0), 21, 0)},
+			want:    []Sample{sample(at(30, 10, 15, 0), 20, 0), sample(at(30, 10, 31, 0), 21, 0)},
+		},
+		{
+			name:    "downsampled samples are weighted by their count",
+			samples: []Sample{sample(at(30, 11, 0, 0), 20, 3), sample(at(30, 11, 10, 0), 24, 0)},
+			want:    []Sample{sample(at(30, 11, 0, 0), 21, 4)},
+		},
+		{
+			name:    "the last window before the threshold",
+			samples: []Sample{sample(at(30, 11, 50, 0), 20, 0), sample(at(30, 11, 59, 59), 22, 0)},
+			want:    []Sample{sample(at(30, 11, 45, 0), 21, 2)},
+		},
+		{
+			// the threshold is truncated to the resolution so that a window
+			// isn't averaged before all of its samples have been recorded
+			name:    "at the threshold",
+			samples: []Sample{sample(at(30, 12, 0, 0), 20, 0), sample(at(30, 12, 7, 0), 22, 0), sample(at(30, 12, 10, 0), 24, 0)},
+			want:    []Sample{sample(at(30, 12, 0, 0), 20, 0), sample(at(30, 12, 7, 0), 22, 0), sample(at(30, 12, 10, 0), 24, 0)},
+		},
+		{
+			name:    "recent",
+			samples: []Sample{sample(at(31, 11, 0, 0), 20, 0), sample(at(31, 11, 1, 0), 22, 0)},
+			want:    []Sample{sample(at(31, 11, 0, 0), 20, 0), sample(at(31, 11, 1, 0), 22, 0)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Open(filepath.Join(t.TempDir(), "history.db"), WithRetention(7*24*time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			for _, sample := range tt.samples {
+				if err := s.Record("lounge", sample); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// compacting twice shows it's idempotent
+			for i := 0; i < 2; i++ {
+				if err := s.Compact(now); err != nil {
+					t.Fatal(err)
+				}
+				got, err := s.Query("lounge", now.AddDate(0, -1, 0), now)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for j := range got {
+					got[j].Time = got[j].Time.UTC()
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Compact() left %+v, want %+v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompactEachDevice(t *testing.T) {
+	now := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+	s, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	old := now.Add(-48 * time.Hour)
+	for _, device := range []string{"lounge", "bedroom"} {
+		for i := 0; i < 3; i++ {
+			if err := s.Record(device, Sample{Time: old.Add(time.Duration(i) * time.Minute), Temperature: 20}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := s.Compact(now); err != nil {
+		t.Fatal(err)
+	}
+	for _, device := range []string{"lounge", "bedroom"} {
+		got, err := s.Query(device, now.AddDate(0, -1, 0), now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0].Count != 3 {
+			t.Errorf("%s compacted to %+v, want a single sample of 3", device, got)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package history
+
+import "time"
+
+// a polled value of a sample
+type Field string
+
+const (
+	Temperature Field = "temperature"
+	Setpoint    Field = "setpoint"
+)
+
+// the value of the field within the sample
+func (f Field) Value(s Sample) float64 {
+	if f == Setpoint {
+		return s.Setpoint
+	}
+	return s.Temperature
+}
+
+// the statistics of a field over a range of samples, downsampled samples
+// are weighted by the number of samples they average
+type Summary struct {
+	Field   Field
+	Samples int
+	Min     float64
+	MinAt   time.Time
+	Max     float64
+	MaxAt   time.Time
+	Mean    float64
+	First   Sample
+	Last    Sample
+	// the fraction of the samples where the power was on
+	PowerOn float64
+}
+
+// summarise the field over the samples, false when there are none
+func Summarize(samples []Sample, f Field) (Summary, bool) {
+	if len(samples) == 0 {
+		return Summary{}, false
+	}
+	s := Summary{
+		Field: f,
+		Min:   f.Value(samples[0]),
+		MinAt: samples[0].Time,
+		Max:   f.Value(samples[0]),
+		MaxAt: samples[0].Time,
+		First: samples[0],
+		Last:  samples[len(samples)-1],
+	}
+	var total, on float64
+	for _, sample := range samples {
+		v, w := f.Value(sample), sample.weight()
+		if v < s.Min {
+			s.Min, s.MinAt = v, sample.Time
+		}
+		if v > s.Max {
+			s.Max, s.MaxAt = v, sample.Time
+		}
+		s.Mean += v * w
+		if sample.Power {
+			on += w
+		}
+		total += w
+		s.Samples += int(w)
+	}
+	s.Mean /= total
+	s.PowerOn = on / total
+	return s, true
+}
@@ -0,0 +1,151 @@
+package hvac
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// a single key & value to set on the device
+type Setting struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// the order keys are applied in, so that eg. the setpoint is validated
+// against the new mode. keys which aren't listed are applied afterwards
+var applyOrder = []string{"power", "mode", "setpoint", "fan_speed", "vvane", "quiet_mode"}
+
+// the keys captured by a snapshot
+var snapshotKeys = []string{"mode", "setpoint", "fan_speed", "vvane", "quiet_mode"}
+
+// what happened to a setting during Apply
+type Outcome int
+
+const (
+	Applied Outcome = iota
+	Unchanged
+	Failed
+	Skipped
+	RolledBack
+	RollbackFailed
+)
+
+var outcomeNames = []string{"applied", "unchanged", "failed", "skipped", "rolled_back", "rollback_failed"}
+
+func (o Outcome) String() string {
+	if int(o) < len(outcomeNames) {
+		return outcomeNames[o]
+	}
+	return "unknown"
+}
+
+// the outcome of a single setting, Previous is the value before Apply
+type Change struct {
+	Setting
+	Previous string
+	Outcome  Outcome
+	Result   *SetResult
+	Err      error
+}
+
+// the current value of key in the form Set accepts, false when it isn't
+// one of the settable keys
+func (h *HVACStatus) Value(key string) (string, bool) {
+	switch key {
+	case "power":
+		return h.Status.Power, true
+	case "mode":
+		return h.Status.Mode, true
+	case "setpoint":
+		return strconv.FormatFloat(h.SetpointTemperature(), 'f', -1, 64), true
+	case "fan_speed":
+		return strconv.Itoa(h.Status.FanSpeed), true
+	case "vvane":
+		return h.Status.Vvane, true
+	case "quiet_mode":
+		return h.Status.QuietMode, true
+	}
+	return "", false
+}
+
+// the current mode, setpoint, fan speed, vane & quiet mode as settings which
+// Apply can restore
+func (h *HVACStatus) Snapshot() []Setting {
+	settings := []Setting{}
+	for _, key := range snapshotKeys {
+		if value, ok := h.Value(key); ok && value != "" {
+			settings = append(settings, Setting{Key: key, Value: value})
+		}
+	}
+	return settings
+}
+
+// set each setting in a defined order, skipping those already at their value.
+// when a setting fails those already applied are rolled back to their
+// previous values & the rest are skipped, the error is that of the failure
+func (h *Hvac) Apply(ctx context.Context, settings []Setting) ([]Change, error) {
+	status, err := h.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes := []Change{}
+	for _, s := range Ordered(settings) {
+		previous, _ := status.Value(s.Key)
+		changes = append(changes, Change{Setting: s, Previous: previous, Outcome: Skipped})
+	}
+	for i := range changes {
+		c := &changes[i]
+		if c.Previous != "" && strings.EqualFold(c.Previous, c.Value) {
+			c.Outcome = Unchanged
+			continue
+		}
+		c.Result, c.Err = h.Set(ctx, c.Key, c.Value)
+		if c.Err == nil {
+			c.Outcome = Applied
+			continue
+		}
+		c.Outcome = Failed
+		h.logger.Printf("apply failed at: %s=%s rolling back cause: %v", c.Key, c.Value, c.Err)
+		h.rollback(ctx, changes[:i])
+		return changes, c.Err
+	}
+	return changes, nil
+}
+
+// restore the applied changes to their previous values in reverse order
+func (h *Hvac) rollback(ctx context.Context, changes []Change) {
+	for i := len(changes) - 1; i >= 0; i-- {
+		c := &changes[i]
+		if c.Outcome != Applied || c.Previous == "" {
+			continue
+		}
+		if _, err := h.Set(ctx, c.Key, c.Previous); err != nil {
+			h.logger.Printf("rollback failed for: %s=%s cause: %v", c.Key, c.Previous, err)
+			c.Outcome, c.Err = RollbackFailed, err
+			continue
+		}
+		c.Outcome = RolledBack
+	}
+}
+
+// a copy of settings in the order they should be applied
+func Ordered(settings []Setting) []Setting {
+	rank := func(key string) int {
+		for i, k := range applyOrder {
+			if strings.EqualFold(k, key) {
+				return i
+			}
+		}
+		return len(applyOrder)
+	}
+	o := append([]Setting{}, settings...)
+	sort.SliceStable(o, func(i, j int) bool {
+		if rank(o[i].Key) != rank(o[j].Key) {
+			return rank(o[i].Key) < rank(o[j].Key)
+		}
+		return o[i].Key < o[j].Key
+	})
+	return o
+}
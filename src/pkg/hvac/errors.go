@@ -0,0 +1,95 @@
+package hvac
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// the service-intesis API could not be reached or the response could not be read
+	ErrTransport = errors.New("transport failure")
+	// the service-intesis API responded with a non 2xx status code
+	ErrStatus = errors.New("unexpected status code")
+	// the service-intesis API response could not be decoded
+	ErrDecode = errors.New("unable to decode response")
+	// the requested key is not present within HVACStatus
+	ErrUnknownKey = errors.New("unknown key")
+	// the set value is outside of the limits reported by the device
+	ErrInvalidValue = errors.New("invalid value")
+)
+
+// wraps a failure to complete the http round trip
+type TransportError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("http %s failed. url: %s cause: %v", e.Method, e.URL, e.Err)
+}
+
+func (e *TransportError) Unwrap() error { return e.Err }
+
+func (e *TransportError) Is(target error) bool { return target == ErrTransport }
+
+// a response outside of the 2xx range
+type StatusError struct {
+	Code int
+	URL  string
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("invalid status code: %d url: %s body: %s", e.Code, e.URL, e.Body)
+}
+
+func (e *StatusError) Is(target error) bool { return target == ErrStatus }
+
+// a response body which isn't a valid HVACStatus
+type DecodeError struct {
+	Body string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("unable to decode: %s cause: %v", e.Body, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+func (e *DecodeError) Is(target error) bool { return target == ErrDecode }
+
+// a key which doesn't match any field within Status, Suggestion is the closest
+// known key if there is one
+type UnknownKeyError struct {
+	Key        string
+	Suggestion string
+}
+
+func (e *UnknownKeyError) Error() string {
+	if e.Suggestion != "" {
+		return fmt.Sprintf("unknown key: `%s`, did you mean `%s`?", e.Key, e.Suggestion)
+	}
+	return fmt.Sprintf("unknown key: `%s`", e.Key)
+}
+
+func (e *UnknownKeyError) Is(target error) bool { return target == ErrUnknownKey }
+
+// a set value which is outside of the limits reported by the device
+type ValidationError struct {
+	Key     string
+	Value   string
+	Reason  string
+	Allowed []string
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Allowed) > 0 {
+		return fmt.Sprintf("invalid value `%s` for `%s`, allowed values are: %s", e.Value, e.Key, strings.Join(e.Allowed, ", "))
+	}
+	return fmt.Sprintf("invalid value `%s` for `%s`, %s", e.Value, e.Key, e.Reason)
+}
+
+func (e *ValidationError) Is(target error) bool { return target == ErrInvalidValue }
@@ -0,0 +1,110 @@
+package hvac
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// the maximum edit distance for a key to be offered as a suggestion
+const maxSuggestDistance int = 3
+
+// looks up a single field of Status by either its Go field name or its json tag
+// in any case, returning the json tag & value. unknown keys return an error
+// which suggests the closest known key
+func (h *HVACStatus) Field(key string) (string, interface{}, error) {
+	v := reflect.ValueOf(h.Status)
+	s := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		tag := jsonTag(s.Field(i))
+		if strings.EqualFold(key, tag) || strings.EqualFold(key, s.Field(i).Name) {
+			return tag, v.Field(i).Interface(), nil
+		}
+	}
+	return "", nil, &UnknownKeyError{Key: key, Suggestion: suggest(key, StatusKeys())}
+}
+
+// returns the json tags of every field within Status
+func StatusKeys() []string {
+	keys := []string{}
+	s := reflect.TypeOf(HVACStatus{}.Status)
+	for i := 0; i < s.NumField(); i++ {
+		keys = append(keys, jsonTag(s.Field(i)))
+	}
+	return keys
+}
+
+// the json tag name of a struct field, falling back to the field name
+func jsonTag(f reflect.StructField) string {
+	tag := strings.Split(f.Tag.Get("json"), ",")[0]
+	if tag == "" {
+		return f.Name
+	}
+	return tag
+}
+
+// find the closest candidate to key, or an empty string if nothing is close enough
+func suggest(key string, candidates []string) string {
+	best := ""
+	bestDistance := maxSuggestDistance + 1
+	for _, c := range candidates {
+		d := levenshtein(strings.ToLower(key), strings.ToLower(c))
+		if d < bestDistance {
+			best = c
+			bestDistance = d
+		}
+	}
+	return best
+}
+
+// the edit distance between two strings
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(first int, rest ...int) int {
+	m := first
+	for _, v := range rest {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// the room temperature in degrees celsius
+func (h *HVACStatus) RoomTemperature() float64 {
+	return scaled(h.Status.Temperature)
+}
+
+// the target temperature in degrees celsius
+func (h *HVACStatus) SetpointTemperature() float64 {
+	return scaled(h.Status.Setpoint)
+}
+
+// the fan speed name as mapped through config_fan_map, or the raw index when
+// the device doesn't report a name for it
+func (h *HVACStatus) FanSpeedName() string {
+	index := strconv.Itoa(h.Status.FanSpeed)
+	if name, ok := h.FanMap()[index]; ok {
+		return name
+	}
+	return index
+}
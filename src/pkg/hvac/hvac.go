@@ -2,14 +2,19 @@ package hvac
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math/rand"
 	"net/http"
 	"os"
 	"reflect"
 	"strings"
+	"sync"
+	"time"
 )
 
 type HVACSet struct {
@@ -17,6 +22,14 @@ type HVACSet struct {
 	Value string `json:"value"`
 }
 
+// the outcome of a successful Set, Response is the raw body returned by the API
+type SetResult struct {
+	Param    string
+	Value    string
+	Previous string // the value before it was set, empty when unknown
+	Response string
+}
+
 type HVACStatus struct {
 	Device struct {
 		ID             string `json:"id"`
@@ -79,17 +92,40 @@ type HVACStatus struct {
 }
 
 type Hvac struct {
-	logger *log.Logger
-	api    string
-	device string
+	logger     *log.Logger
+	api        string
+	device     string
+	client     *http.Client
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	// when each key was last successfully set by us
+	mu        sync.Mutex
+	lastSet   map[string]time.Time
+	observers []Observer
+	watchers  []StatusObserver
+	// the outcome of the most recent request to the api
+	lastSuccess time.Time
+	lastErr     error
 }
 
+// called with the outcome of every request to the api, including retries
+type Observer func(method string, err error)
+
+// called with every status fetched from the device, whether it was polled or
+// read for a command
+type StatusObserver func(s *HVACStatus)
+
 const (
-	defaultApi     string = "http://127.0.0.1:2112"
-	defaultDevice  string = "127934703953"
-	deviceEndpoint string = "%s/hvac/%s"
-	postMethod     string = "post"
-	getMethod      string = "get"
+	defaultApi        string        = "http://127.0.0.1:2112"
+	defaultDevice     string        = "127934703953"
+	defaultTimeout    time.Duration = 10 * time.Second
+	defaultRetries    int           = 3
+	defaultBackoff    time.Duration = 250 * time.Millisecond
+	defaultMaxBackoff time.Duration = 5 * time.Second
+	deviceEndpoint    string        = "%s/hvac/%s"
+	postMethod        string        = http.MethodPost
+	getMethod         string        = http.MethodGet
 )
 
 type HvacOption func(h *Hvac)
@@ -112,11 +148,64 @@ func WithDevice(d string) HvacOption {
 	}
 }
 
+// the http client used for calls to the API, overrides WithTimeout
+func WithHTTPClient(c *http.Client) HvacOption {
+	return func(h *Hvac) {
+		h.client = c
+	}
+}
+
+// the timeout of each individual http call to the API
+func WithTimeout(t time.Duration) HvacOption {
+	return func(h *Hvac) {
+		h.client = &http.Client{Timeout: t}
+	}
+}
+
+// the number of times an idempotent call is retried after the first attempt
+func WithRetries(r int) HvacOption {
+	return func(h *Hvac) {
+		h.retries = r
+	}
+}
+
+// the initial & maximum delay between retries, the delay doubles on each
+// attempt up to max & is jittered. zero values retain the defaults
+func WithBackoff(initial, max time.Duration) HvacOption {
+	return func(h *Hvac) {
+		if initial > 0 {
+			h.backoff = initial
+		}
+		if max > 0 {
+			h.maxBackoff = max
+		}
+	}
+}
+
+// observe every request to the api, eg. for metrics
+func WithObserver(o Observer) HvacOption {
+	return func(h *Hvac) {
+		h.observers = append(h.observers, o)
+	}
+}
+
+// observe every status fetched from the device, eg. for the device gauges
+func WithStatusObserver(o StatusObserver) HvacOption {
+	return func(h *Hvac) {
+		h.watchers = append(h.watchers, o)
+	}
+}
+
 func New(opts ...HvacOption) *Hvac {
 	h := &Hvac{
-		logger: log.New(os.Stdout, "Hvac: ", log.Ldate|log.Ltime|log.Lshortfile),
-		api:    defaultApi,
-		device: defaultDevice,
+		logger:     log.New(os.Stdout, "Hvac: ", log.Ldate|log.Ltime|log.Lshortfile),
+		api:        defaultApi,
+		device:     defaultDevice,
+		client:     &http.Client{Timeout: defaultTimeout},
+		retries:    defaultRetries,
+		backoff:    defaultBackoff,
+		maxBackoff: defaultMaxBackoff,
+		lastSet:    map[string]time.Time{},
 	}
 	for _, opt := range opts {
 		opt(h)
@@ -130,36 +219,62 @@ func (h *Hvac) deviceEndpoint() string {
 	return fmt.Sprintf(deviceEndpoint, h.api, h.device)
 }
 
-// fetch the status from the service-intesis endpoint
-func (h *Hvac) Status() string {
-	body, err := httpCall(h.deviceEndpoint(), getMethod, nil)
+// fetch & decode the status from the service-intesis endpoint
+func (h *Hvac) Status(ctx context.Context) (*HVACStatus, error) {
+	body, err := h.httpCall(ctx, getMethod, nil)
 	if err != nil {
-		return fmt.Sprintf(":x: %v", err)
+		return nil, err
 	}
 	status := &HVACStatus{}
 	if err = json.Unmarshal([]byte(body), &status); err != nil {
-		e := fmt.Sprintf(":x: unable to decode: %s", string(body))
-		h.logger.Printf(e)
-		return e
+		h.logger.Printf("unable to decode: %s", body)
+		return nil, &DecodeError{Body: body, Err: err}
+	}
+	for _, o := range h.watchers {
+		o(status)
 	}
-	return status.String()
+	return status, nil
 }
 
-// performs a set for a key value pair against the API
-func (h *Hvac) Set(key, value string) string {
+// fetch the status & return only the json key & value of the requested key
+func (h *Hvac) Get(ctx context.Context, key string) (string, interface{}, error) {
+	status, err := h.Status(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	return status.Field(key)
+}
+
+// performs a set for a key value pair against the API, the value is first
+// validated against the limits reported by the device
+func (h *Hvac) Set(ctx context.Context, key, value string) (*SetResult, error) {
+	status, err := h.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// the device only accepts json tags, so eg. Setpoint is validated & posted
+	// as setpoint
+	if key, _, err = status.Field(key); err != nil {
+		return nil, err
+	}
+	if err := status.Validate(key, value); err != nil {
+		return nil, err
+	}
 	payload := &HVACSet{Param: key, Value: value}
 	var buf bytes.Buffer
-	err := json.NewEncoder(&buf).Encode(payload)
-	if err != nil {
-		e := fmt.Sprintf("unable to encode: %v to json. cause: %v", payload, err)
-		h.logger.Printf(e)
-		return e
+	if err := json.NewEncoder(&buf).Encode(payload); err != nil {
+		h.logger.Printf("unable to encode: %v to json. cause: %v", payload, err)
+		return nil, fmt.Errorf("unable to encode: %v to json. cause: %w", payload, err)
 	}
-	body, err := httpCall(h.deviceEndpoint(), postMethod, &buf)
+	body, err := h.httpCall(ctx, postMethod, buf.Bytes())
 	if err != nil {
-		return fmt.Sprintf(":x: %v", err)
+		return nil, err
 	}
-	return fmt.Sprintf(":+1: `%s`", string(body))
+	h.mu.Lock()
+	h.lastSet[key] = time.Now()
+	h.mu.Unlock()
+	previous, _ := status.Value(key)
+	return &SetResult{Param: key, Value: value, Previous: previous, Response: body}, nil
 }
 
 // enumerate the fields of Status & return them as a new line delimited key: value pair string
@@ -173,26 +288,104 @@ func (h *HVACStatus) String() string {
 	return strings.TrimRight(ret, "\n")
 }
 
-// common method for http calls
-func httpCall(endpoint, method string, payload *bytes.Buffer) (string, error) {
-	var (
-		resp *http.Response
-		err  error
-	)
-	if method == postMethod {
-		resp, err = http.Post(endpoint, "application/json", payload)
-	} else {
-		resp, err = http.Get(endpoint)
+// common method for http calls, GETs are idempotent & are retried with
+// backoff on transport failures & 5xx or 429 responses
+func (h *Hvac) httpCall(ctx context.Context, method string, payload []byte) (string, error) {
+	attempts := 1
+	if method == getMethod {
+		attempts += h.retries
+	}
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			delay := h.retryDelay(attempt)
+			h.logger.Printf("retrying %s %s in %s. attempt: %d cause: %v", method, h.deviceEndpoint(), delay, attempt, err)
+			select {
+			case <-ctx.Done():
+				return "", &TransportError{Method: method, URL: h.deviceEndpoint(), Err: ctx.Err()}
+			case <-time.After(delay):
+			}
+		}
+		var body string
+		body, err = h.httpDo(ctx, method, payload)
+		h.record(err)
+		for _, o := range h.observers {
+			o(method, err)
+		}
+		// a cancelled or expired context will fail every subsequent attempt
+		if err == nil || !retryable(err) || ctx.Err() != nil {
+			return body, err
+		}
 	}
+	return "", err
+}
+
+// record the outcome of a request for Healthy
+func (h *Hvac) record(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastErr = err
+	if err == nil {
+		h.lastSuccess = time.Now()
+	}
+}
+
+// nil unless the most recent request to the api failed & none has succeeded
+// within grace, so that a single blip doesn't mark the api as down
+func (h *Hvac) Healthy(grace time.Duration) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.lastErr == nil || time.Since(h.lastSuccess) < grace {
+		return nil
+	}
+	if h.lastSuccess.IsZero() {
+		return fmt.Errorf("no successful request yet, last error: %w", h.lastErr)
+	}
+	return fmt.Errorf("no successful request since %s, last error: %w", h.lastSuccess.Format(time.RFC3339), h.lastErr)
+}
+
+// a single http round trip to the device endpoint
+func (h *Hvac) httpDo(ctx context.Context, method string, payload []byte) (string, error) {
+	endpoint := h.deviceEndpoint()
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", &TransportError{Method: method, URL: endpoint, Err: err}
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := h.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("http %s failed. url: %s cause: %v", method, endpoint, err)
+		return "", &TransportError{Method: method, URL: endpoint, Err: err}
 	}
+	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("cannot read response body: %s from: %s cause: %v", resp.Body, endpoint, err)
+		return "", &TransportError{Method: method, URL: endpoint, Err: err}
 	}
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return "", fmt.Errorf("invalid status code: %d url: %s body: %s", resp.StatusCode, endpoint, string(body))
+		return "", &StatusError{Code: resp.StatusCode, URL: endpoint, Body: string(body)}
 	}
 	return string(body), nil
 }
+
+// exponential backoff with full jitter for the given retry attempt
+func (h *Hvac) retryDelay(attempt int) time.Duration {
+	delay := h.backoff << (attempt - 1)
+	if delay <= 0 || delay > h.maxBackoff {
+		delay = h.maxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay)))
+}
+
+// whether an error from httpDo is worth retrying
+func retryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= 500 || statusErr.Code == http.StatusTooManyRequests
+	}
+	return errors.Is(err, ErrTransport)
+}
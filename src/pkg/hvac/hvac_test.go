@@ -0,0 +1,85 @@
+package hvac
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// a device which serves testStatus & keeps the last set it was sent
+func fakeDevice(t *testing.T, opts ...HvacOption) (*Hvac, *HVACSet) {
+	t.Helper()
+	posted := &HVACSet{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			if err := json.NewDecoder(r.Body).Decode(posted); err != nil {
+				t.Errorf("undecodable set: %v", err)
+			}
+			w.Write([]byte(`{"status":"ok"}`))
+			return
+		}
+		s := testStatus()
+		s.Status.Setpoint = 210
+		json.NewEncoder(w).Encode(s)
+	}))
+	t.Cleanup(srv.Close)
+	opts = append([]HvacOption{WithApi(srv.URL), WithDevice("1"), WithRetries(0), WithLogger(log.New(io.Discard, "", 0))}, opts...)
+	h := New(opts...)
+	return h, posted
+}
+
+func TestSetNormalizesKey(t *testing.T) {
+	for _, key := range []string{"setpoint", "Setpoint", "SETPOINT"} {
+		h, posted := fakeDevice(t)
+		res, err := h.Set(context.Background(), key, "22")
+		if err != nil {
+			t.Fatalf("Set(%s) = %v", key, err)
+		}
+		if posted.Param != "setpoint" || res.Param != "setpoint" {
+			t.Errorf("Set(%s) posted %q & returned %q, want setpoint", key, posted.Param, res.Param)
+		}
+		if res.Previous != "21" {
+			t.Errorf("Set(%s) previous = %q, want 21", key, res.Previous)
+		}
+	}
+}
+
+func TestSetValidatesAnyCase(t *testing.T) {
+	// cool mode limits the setpoint to 18-28
+	for _, key := range []string{"setpoint", "Setpoint", "SETPOINT"} {
+		h, posted := fakeDevice(t)
+		if _, err := h.Set(context.Background(), key, "99"); !errors.Is(err, ErrInvalidValue) {
+			t.Errorf("Set(%s, 99) = %v, want %v", key, err, ErrInvalidValue)
+		}
+		if posted.Param != "" {
+			t.Errorf("Set(%s, 99) posted %+v", key, posted)
+		}
+	}
+}
+
+func TestSetUnknownKey(t *testing.T) {
+	h, _ := fakeDevice(t)
+	if _, err := h.Set(context.Background(), "setpiont", "22"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Set(setpiont) = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestStatusObserver(t *testing.T) {
+	var observed []*HVACStatus
+	h, _ := fakeDevice(t, WithStatusObserver(func(s *HVACStatus) { observed = append(observed, s) }))
+	if _, err := h.Status(context.Background()); err != nil {
+		t.Fatalf("Status() = %v", err)
+	}
+	// a set reads the status to validate against
+	if _, err := h.Set(context.Background(), "setpoint", "22"); err != nil {
+		t.Fatalf("Set() = %v", err)
+	}
+	if len(observed) != 2 || observed[0].Status.Setpoint != 210 {
+		t.Errorf("observed %d statuses, want 2 with setpoint 210", len(observed))
+	}
+}
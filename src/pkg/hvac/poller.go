@@ -0,0 +1,169 @@
+package hvac
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultPollInterval time.Duration = time.Minute
+
+// the kind of an Event emitted by the Poller
+type EventType string
+
+const (
+	StatusPolled    EventType = "polled"      // every successful poll
+	PollFailed      EventType = "poll_failed" // every failed poll
+	PowerChanged    EventType = "power"
+	ModeChanged     EventType = "mode"
+	SetpointChanged EventType = "setpoint"
+	FanSpeedChanged EventType = "fan_speed"
+	ErrorRaised     EventType = "error_raised"
+	ErrorCleared    EventType = "error_cleared"
+)
+
+// something observed by the Poller. changes carry the previous & current
+// value, External is set when the change wasn't made via Set eg. by a remote
+type Event struct {
+	Type     EventType
+	From     string
+	To       string
+	External bool
+	Status   *HVACStatus
+	Err      error
+	Time     time.Time
+}
+
+// called with each event in the order they're observed
+type Subscriber func(e Event)
+
+// fetches the status of a device on an interval & emits an event for each
+// change between consecutive snapshots
+type Poller struct {
+	hvac        *Hvac
+	interval    time.Duration
+	mu          sync.Mutex
+	subscribers []Subscriber
+	last        *HVACStatus
+	lastPoll    time.Time
+}
+
+type PollerOption func(p *Poller)
+
+// how often the status is fetched, defaults to a minute
+func WithInterval(d time.Duration) PollerOption {
+	return func(p *Poller) {
+		if d > 0 {
+			p.interval = d
+		}
+	}
+}
+
+func NewPoller(h *Hvac, opts ...PollerOption) *Poller {
+	p := &Poller{
+		hvac:     h,
+		interval: defaultPollInterval,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// register a subscriber for every subsequent event
+func (p *Poller) Subscribe(s Subscriber) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.subscribers = append(p.subscribers, s)
+}
+
+// the most recently polled status or nil before the first successful poll
+func (p *Poller) Last() *HVACStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.last
+}
+
+// poll until the context is cancelled, the first poll is immediate & only
+// establishes the baseline for changes
+func (p *Poller) Run(ctx context.Context) {
+	p.hvac.logger.Printf("polling device: %s every: %s", p.hvac.device, p.interval)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetch the status once & emit the resulting events
+func (p *Poller) Poll(ctx context.Context) {
+	status, err := p.hvac.Status(ctx)
+	now := time.Now()
+	if err != nil {
+		p.hvac.logger.Printf("poll failed. device: %s cause: %v", p.hvac.device, err)
+		p.emit([]Event{{Type: PollFailed, Err: err, Time: now}})
+		return
+	}
+	p.mu.Lock()
+	previous, since := p.last, p.lastPoll
+	p.last, p.lastPoll = status, now
+	p.mu.Unlock()
+	events := []Event{{Type: StatusPolled, Status: status, Time: now}}
+	if previous != nil {
+		events = append(events, p.hvac.diff(previous, status, since)...)
+	}
+	for i := range events {
+		events[i].Status, events[i].Time = status, now
+	}
+	p.emit(events)
+}
+
+// call every subscriber with each event
+func (p *Poller) emit(events []Event) {
+	p.mu.Lock()
+	subscribers := append([]Subscriber{}, p.subscribers...)
+	p.mu.Unlock()
+	for _, e := range events {
+		for _, s := range subscribers {
+			s(e)
+		}
+	}
+}
+
+// the changes between two snapshots, since is when the previous was taken
+func (h *Hvac) diff(previous, current *HVACStatus, since time.Time) []Event {
+	events := []Event{}
+	changed := func(t EventType, key, from, to string) {
+		if from != to {
+			events = append(events, Event{Type: t, From: from, To: to, External: !h.setSince(key, since)})
+		}
+	}
+	changed(PowerChanged, "power", previous.Status.Power, current.Status.Power)
+	changed(ModeChanged, "mode", previous.Status.Mode, current.Status.Mode)
+	from, _ := previous.Value("setpoint")
+	to, _ := current.Value("setpoint")
+	changed(SetpointChanged, "setpoint", from, to)
+	changed(FanSpeedChanged, "fan_speed", previous.FanSpeedName(), current.FanSpeedName())
+	was, is := previous.Status.ErrorCode, current.Status.ErrorCode
+	switch {
+	case was == is:
+	case is != 0:
+		events = append(events, Event{Type: ErrorRaised, From: strconv.Itoa(was), To: strconv.Itoa(is), External: true})
+	default:
+		events = append(events, Event{Type: ErrorCleared, From: strconv.Itoa(was), To: strconv.Itoa(is), External: true})
+	}
+	return events
+}
+
+// whether key was successfully set by us at or after t
+func (h *Hvac) setSince(key string, t time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return !h.lastSet[key].Before(t)
+}
@@ -0,0 +1,276 @@
+package hvac
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHysteresis float64       = 0.5
+	defaultMinOnTime  time.Duration = 5 * time.Minute
+	defaultMinOffTime time.Duration = 5 * time.Minute
+	defaultMaxCycles  int           = 4
+)
+
+// what the thermostat did with a polled status
+type ThermostatAction string
+
+const (
+	ThermostatHold  ThermostatAction = "hold"
+	ThermostatStart ThermostatAction = "start"
+	ThermostatStop  ThermostatAction = "stop"
+)
+
+// the range the room temperature is kept within
+type Band struct {
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+// the outcome of evaluating a polled status & why
+type Decision struct {
+	Time        time.Time
+	Temperature float64
+	Action      ThermostatAction
+	Mode        string // the mode the device was started in
+	Reason      string
+	Changes     []Change // the outcome of applying the decision, nil when held
+	Err         error
+}
+
+// called with every decision which changed, or tried to change, the device
+type DecisionSubscriber func(d Decision)
+
+// switches the power & mode of a device from its polled temperature to keep
+// the room within a band. cooling starts above the band plus the hysteresis
+// & stops once the temperature falls to the top of the band less it, heating
+// likewise from the bottom of the band. the compressor is protected by a
+// minimum on & off time & a maximum number of starts per hour
+type Thermostat struct {
+	hvac       *Hvac
+	hysteresis float64
+	minOn      time.Duration
+	minOff     time.Duration
+	maxCycles  int
+
+	mu          sync.Mutex
+	band        *Band // nil when disabled
+	subscribers []DecisionSubscriber
+
+	// the last observed power, when it last changed & the starts within the hour
+	power    string
+	switched time.Time
+	starts   []time.Time
+	last     Decision
+}
+
+type ThermostatOption func(t *Thermostat)
+
+// how far beyond the band the temperature goes before acting, defaults to 0.5°C
+func WithHysteresis(degrees float64) ThermostatOption {
+	return func(t *Thermostat) {
+		if degrees > 0 {
+			t.hysteresis = degrees
+		}
+	}
+}
+
+// how long the device is left on & off before switching it again, both
+// default to 5m
+func WithMinRuntime(on, off time.Duration) ThermostatOption {
+	return func(t *Thermostat) {
+		if on > 0 {
+			t.minOn = on
+		}
+		if off > 0 {
+			t.minOff = off
+		}
+	}
+}
+
+// the most times the device is started within an hour, defaults to 4
+func WithMaxCycles(n int) ThermostatOption {
+	return func(t *Thermostat) {
+		if n > 0 {
+			t.maxCycles = n
+		}
+	}
+}
+
+// a disabled thermostat for the device
+func NewThermostat(h *Hvac, opts ...ThermostatOption) *Thermostat {
+	t := &Thermostat{
+		hvac:       h,
+		hysteresis: defaultHysteresis,
+		minOn:      defaultMinOnTime,
+		minOff:     defaultMinOffTime,
+		maxCycles:  defaultMaxCycles,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// enable the thermostat within the band
+func (t *Thermostat) Enable(b Band) error {
+	if b.Low >= b.High {
+		return fmt.Errorf("the low of the band %.1f°C must be below the high %.1f°C", b.Low, b.High)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.band = &b
+	return nil
+}
+
+// stop controlling the device, leaving it as it is
+func (t *Thermostat) Disable() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.band = nil
+}
+
+// the band, false when disabled
+func (t *Thermostat) Band() (Band, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.band == nil {
+		return Band{}, false
+	}
+	return *t.band, true
+}
+
+// the most recent decision, the zero value before the first
+func (t *Thermostat) Last() Decision {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.last
+}
+
+// subscribe to the decisions which change the device
+func (t *Thermostat) Subscribe(s DecisionSubscriber) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.subscribers = append(t.subscribers, s)
+}
+
+// the poller subscriber which evaluates each polled status, settings are
+// applied within ctx
+func (t *Thermostat) Subscriber(ctx context.Context) Subscriber {
+	return func(e Event) {
+		if e.Type != StatusPolled {
+			return
+		}
+		t.Evaluate(ctx, e.Status, e.Time)
+	}
+}
+
+// decide what to do with the polled status & apply it, every decision is
+// logged with its reason
+func (t *Thermostat) Evaluate(ctx context.Context, status *HVACStatus, now time.Time) Decision {
+	t.mu.Lock()
+	t.observe(status, now)
+	if t.band == nil {
+		t.mu.Unlock()
+		return Decision{}
+	}
+	band := *t.band
+	d, settings := t.decide(band, status, now)
+	t.mu.Unlock()
+	if d.Action != ThermostatHold {
+		d.Changes, d.Err = t.hvac.Apply(ctx, settings)
+	}
+	msg := fmt.Sprintf("thermostat device: %s temperature: %.1f band: %.1f-%.1f action: %s", t.hvac.device, d.Temperature, band.Low, band.High, d.Action)
+	if d.Mode != "" {
+		msg += " mode: " + d.Mode
+	}
+	msg += " reason: " + d.Reason
+	if d.Err != nil {
+		msg += fmt.Sprintf(" cause: %v", d.Err)
+	}
+	t.hvac.logger.Print(msg)
+	t.mu.Lock()
+	t.last = d
+	subscribers := t.subscribers
+	t.mu.Unlock()
+	if d.Action != ThermostatHold {
+		for _, s := range subscribers {
+			s(d)
+		}
+	}
+	return d
+}
+
+// track when the power changes & the starts within the last hour, whether
+// made by us or not
+func (t *Thermostat) observe(status *HVACStatus, now time.Time) {
+	power := status.Status.Power
+	if t.power != "" && power != t.power {
+		t.switched = now
+		if power == "on" {
+			t.starts = append(t.starts, now)
+		}
+	}
+	t.power = power
+	recent := []time.Time{}
+	for _, s := range t.starts {
+		if now.Sub(s) < time.Hour {
+			recent = append(recent, s)
+		}
+	}
+	t.starts = recent
+}
+
+// the decision for the status & the settings which carry it out
+func (t *Thermostat) decide(b Band, status *HVACStatus, now time.Time) (Decision, []Setting) {
+	temp := status.RoomTemperature()
+	d := Decision{Time: now, Temperature: temp, Action: ThermostatHold}
+	mode := status.Status.Mode
+	on := status.Status.Power == "on"
+	switch {
+	case on && mode == "cool" && temp > b.High-t.hysteresis:
+		d.Reason = fmt.Sprintf("cooling until %.1f°C", b.High-t.hysteresis)
+	case on && mode == "heat" && temp < b.Low+t.hysteresis:
+		d.Reason = fmt.Sprintf("heating until %.1f°C", b.Low+t.hysteresis)
+	case on && (mode == "cool" || mode == "heat"):
+		reason := fmt.Sprintf("%.1f°C is at or below %.1f°C", temp, b.High-t.hysteresis)
+		if mode == "heat" {
+			reason = fmt.Sprintf("%.1f°C is at or above %.1f°C", temp, b.Low+t.hysteresis)
+		}
+		if ran := now.Sub(t.switched); ran < t.minOn {
+			d.Reason = fmt.Sprintf("%s but the device has only been on for %s of the minimum %s", reason, ran.Truncate(time.Second), t.minOn)
+			return d, nil
+		}
+		d.Action, d.Reason = ThermostatStop, reason
+		return d, []Setting{{Key: "power", Value: "off"}}
+	case temp > b.High+t.hysteresis:
+		return t.start(d, status, "cool", fmt.Sprintf("%.1f°C is above %.1f°C", temp, b.High+t.hysteresis), now)
+	case temp < b.Low-t.hysteresis:
+		return t.start(d, status, "heat", fmt.Sprintf("%.1f°C is below %.1f°C", temp, b.Low-t.hysteresis), now)
+	case on:
+		d.Reason = fmt.Sprintf("%.1f°C is within the band, leaving the device in %s", temp, mode)
+	default:
+		d.Reason = fmt.Sprintf("%.1f°C is within the band", temp)
+	}
+	return d, nil
+}
+
+// start the device in mode unless the compressor guards or the device
+// prevent it
+func (t *Thermostat) start(d Decision, status *HVACStatus, mode, reason string, now time.Time) (Decision, []Setting) {
+	on := status.Status.Power == "on"
+	switch {
+	case status.Validate("mode", mode) != nil:
+		d.Reason = fmt.Sprintf("%s but the device can't %s", reason, mode)
+	case !on && now.Sub(t.switched) < t.minOff:
+		d.Reason = fmt.Sprintf("%s but the device has only been off for %s of the minimum %s", reason, now.Sub(t.switched).Truncate(time.Second), t.minOff)
+	case len(t.starts) >= t.maxCycles:
+		d.Reason = fmt.Sprintf("%s but the device has already started %d times within the hour", reason, len(t.starts))
+	default:
+		d.Action, d.Mode, d.Reason = ThermostatStart, mode, reason
+		return d, []Setting{{Key: "power", Value: "on"}, {Key: "mode", Value: mode}}
+	}
+	return d, nil
+}
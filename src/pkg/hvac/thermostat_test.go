@@ -0,0 +1,145 @@
+package hvac
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecide(t *testing.T) {
+	now := time.Date(2026, 1, 10, 15, 0, 0, 0, time.UTC)
+	band := Band{Low: 20, High: 24}
+	tests := []struct {
+		name        string
+		power       string
+		mode        string
+		temperature int // tenths of a degree as reported by the device
+		switched    time.Duration
+		starts      int
+		modes       int // config_mode_map, defaults to auto, heat & cool
+		action      ThermostatAction
+		settings    []Setting
+	}{
+		{name: "off within the band", power: "off", mode: "cool", temperature: 220, switched: time.Hour, action: ThermostatHold},
+		{name: "off at the top of the hysteresis", power: "off", mode: "cool", temperature: 245, switched: time.Hour, action: ThermostatHold},
+		{
+			name: "off above the hysteresis", power: "off", mode: "heat", temperature: 246, switched: time.Hour, action: ThermostatStart,
+			settings: []Setting{{Key: "power", Value: "on"}, {Key: "mode", Value: "cool"}},
+		},
+		{name: "off at the bottom of the hysteresis", power: "off", mode: "heat", temperature: 195, switched: time.Hour, action: ThermostatHold},
+		{
+			name: "off below the hysteresis", power: "off", mode: "cool", temperature: 194, switched: time.Hour, action: ThermostatStart,
+			settings: []Setting{{Key: "power", Value: "on"}, {Key: "mode", Value: "heat"}},
+		},
+		{name: "cooling above the stop point", power: "on", mode: "cool", temperature: 236, switched: time.Hour, action: ThermostatHold},
+		{
+			name: "cooling at the stop point", power: "on", mode: "cool", temperature: 235, switched: time.Hour, action: ThermostatStop,
+			settings: []Setting{{Key: "power", Value: "off"}},
+		},
+		{
+			name: "cooling overshot the band", power: "on", mode: "cool", temperature: 190, switched: time.Hour, action: ThermostatStop,
+			settings: []Setting{{Key: "power", Value: "off"}},
+		},
+		{name: "heating below the stop point", power: "on", mode: "heat", temperature: 204, switched: time.Hour, action: ThermostatHold},
+		{
+			name: "heating at the stop point", power: "on", mode: "heat", temperature: 205, switched: time.Hour, action: ThermostatStop,
+			settings: []Setting{{Key: "power", Value: "off"}},
+		},
+		{name: "stop within the minimum on time", power: "on", mode: "cool", temperature: 230, switched: 4 * time.Minute, action: ThermostatHold},
+		{
+			name: "stop at the minimum on time", power: "on", mode: "cool", temperature: 230, switched: 5 * time.Minute, action: ThermostatStop,
+			settings: []Setting{{Key: "power", Value: "off"}},
+		},
+		{name: "start within the minimum off time", power: "off", mode: "cool", temperature: 260, switched: 4 * time.Minute, action: ThermostatHold},
+		{
+			name: "start at the minimum off time", power: "off", mode: "cool", temperature: 260, switched: 5 * time.Minute, action: ThermostatStart,
+			settings: []Setting{{Key: "power", Value: "on"}, {Key: "mode", Value: "cool"}},
+		},
+		{name: "start at the maximum cycles", power: "off", mode: "cool", temperature: 260, switched: time.Hour, starts: 4, action: ThermostatHold},
+		{
+			name: "start below the maximum cycles", power: "off", mode: "cool", temperature: 260, switched: time.Hour, starts: 3, action: ThermostatStart,
+			settings: []Setting{{Key: "power", Value: "on"}, {Key: "mode", Value: "cool"}},
+		},
+		{name: "start in an unsupported mode", power: "off", mode: "heat", temperature: 260, switched: time.Hour, modes: 2, action: ThermostatHold},
+		{
+			name: "on in another mode above the band", power: "on", mode: "fan", temperature: 260, switched: time.Minute, action: ThermostatStart,
+			settings: []Setting{{Key: "power", Value: "on"}, {Key: "mode", Value: "cool"}},
+		},
+		{name: "on in another mode within the band", power: "on", mode: "fan", temperature: 220, switched: time.Hour, action: ThermostatHold},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := NewThermostat(nil)
+			th.switched = now.Add(-tt.switched)
+			for i := 0; i < tt.starts; i++ {
+				th.starts = append(th.starts, now.Add(-time.Duration(i+1)*time.Minute))
+			}
+			s := testStatus()
+			s.Status.Power, s.Status.Mode, s.Status.Temperature = tt.power, tt.mode, tt.temperature
+			if tt.modes != 0 {
+				s.Status.ConfigModeMap = tt.modes
+			}
+			d, settings := th.decide(band, s, now)
+			if d.Action != tt.action {
+				t.Errorf("decide() action = %s, want %s reason: %s", d.Action, tt.action, d.Reason)
+			}
+			if !reflect.DeepEqual(settings, tt.settings) {
+				t.Errorf("decide() settings = %v, want %v", settings, tt.settings)
+			}
+			if d.Reason == "" {
+				t.Error("decide() gave no reason")
+			}
+		})
+	}
+}
+
+func TestObserve(t *testing.T) {
+	start := time.Date(2026, 1, 10, 15, 0, 0, 0, time.UTC)
+	th := NewThermostat(nil)
+	s := testStatus()
+	steps := []struct {
+		after    time.Duration
+		power    string
+		switched time.Duration
+		starts   int
+	}{
+		{0, "off", 0, 0},
+		{time.Minute, "on", time.Minute, 1},
+		{2 * time.Minute, "on", time.Minute, 1},
+		{10 * time.Minute, "off", 10 * time.Minute, 1},
+		{20 * time.Minute, "on", 20 * time.Minute, 2},
+		// the first start falls out of the hour
+		{61 * time.Minute, "on", 20 * time.Minute, 1},
+		{80 * time.Minute, "on", 20 * time.Minute, 0},
+	}
+	for _, step := range steps {
+		s.Status.Power = step.power
+		th.observe(s, start.Add(step.after))
+		if want := start.Add(step.switched); step.switched > 0 && !th.switched.Equal(want) {
+			t.Errorf("after %s switched = %s, want %s", step.after, th.switched, want)
+		}
+		if len(th.starts) != step.starts {
+			t.Errorf("after %s starts = %d, want %d", step.after, len(th.starts), step.starts)
+		}
+	}
+}
+
+func TestEnable(t *testing.T) {
+	th := NewThermostat(nil)
+	if err := th.Enable(Band{Low: 24, High: 24}); err == nil {
+		t.Error("Enable() accepted a band without a range")
+	}
+	if _, ok := th.Band(); ok {
+		t.Error("Band() is enabled after an invalid band")
+	}
+	if err := th.Enable(Band{Low: 20, High: 24}); err != nil {
+		t.Errorf("Enable() = %v", err)
+	}
+	if b, ok := th.Band(); !ok || b != (Band{Low: 20, High: 24}) {
+		t.Errorf("Band() = %v %t", b, ok)
+	}
+	th.Disable()
+	if _, ok := th.Band(); ok {
+		t.Error("Band() is enabled after Disable()")
+	}
+}
@@ -0,0 +1,141 @@
+package hvac
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the device reports temperatures in tenths of a degree celsius, whereas set
+// values are in whole (or fractional) degrees
+const temperatureScale float64 = 10
+
+// the intesishome bitmap of supported modes, as reported in config_mode_map
+var modeBits = []struct {
+	bit  int
+	name string
+}{
+	{1, "auto"},
+	{2, "heat"},
+	{4, "dry"},
+	{8, "fan"},
+	{16, "cool"},
+}
+
+var onOff = []string{"on", "off"}
+
+// checks that value is acceptable for key against the limits reported by the
+// device. keys which have no reported limits are allowed through as is
+func (h *HVACStatus) Validate(key, value string) error {
+	switch key {
+	case "power", "quiet_mode":
+		return oneOf(key, value, onOff)
+	case "mode":
+		return oneOf(key, value, h.Modes())
+	case "fan_speed":
+		return oneOf(key, value, h.FanSpeeds())
+	case "setpoint":
+		return h.validateSetpoint(value)
+	}
+	return nil
+}
+
+// the modes which are supported by the device & not currently restricted
+func (h *HVACStatus) Modes() []string {
+	modes := []string{}
+	for _, m := range modeBits {
+		if h.Status.ConfigModeMap&m.bit == 0 || h.Status.RuntimeModeRestrictions&m.bit != 0 {
+			continue
+		}
+		modes = append(modes, m.name)
+	}
+	return modes
+}
+
+// the fan speed names reported by the device in config_fan_map, keyed by index
+func (h *HVACStatus) FanMap() map[string]string {
+	fans := map[string]string{}
+	v := reflect.ValueOf(h.Status.ConfigFanMap)
+	s := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		if name := v.Field(i).String(); name != "" {
+			fans[jsonTag(s.Field(i))] = name
+		}
+	}
+	return fans
+}
+
+// the fan speeds accepted by the device, both the index & name are valid
+func (h *HVACStatus) FanSpeeds() []string {
+	speeds := []string{}
+	for index, name := range h.FanMap() {
+		speeds = append(speeds, index, name)
+	}
+	sort.Strings(speeds)
+	return speeds
+}
+
+// the setpoint must be within the overall & the current mode's limits
+func (h *HVACStatus) validateSetpoint(value string) error {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return &ValidationError{Key: "setpoint", Value: value, Reason: "must be a number"}
+	}
+	min, max := scaled(h.Status.SetpointMin), scaled(h.Status.SetpointMax)
+	inMode := ""
+	switch strings.ToLower(h.Status.Mode) {
+	case "cool":
+		min, max = tighten(min, max, scaled(h.Status.CoolTemperatureMin), scaled(h.Status.CoolTemperatureMax))
+		inMode = " in cool mode"
+	case "heat":
+		min, max = tighten(min, max, scaled(h.Status.HeatTemperatureMin), 0)
+		inMode = " in heat mode"
+	}
+	if (min > 0 && v < min) || (max > 0 && v > max) {
+		return &ValidationError{
+			Key:    "setpoint",
+			Value:  value,
+			Reason: fmt.Sprintf("must be between %s and %s%s", formatLimit(min), formatLimit(max), inMode),
+		}
+	}
+	return nil
+}
+
+// value must case insensitively match one of allowed, an empty allowed list
+// means the device didn't report any limits
+func oneOf(key, value string, allowed []string) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(value, a) {
+			return nil
+		}
+	}
+	return &ValidationError{Key: key, Value: value, Allowed: allowed}
+}
+
+// narrow the min & max to the mode specific limits, zero means no limit
+func tighten(min, max, modeMin, modeMax float64) (float64, float64) {
+	if modeMin > 0 && (min == 0 || modeMin > min) {
+		min = modeMin
+	}
+	if modeMax > 0 && (max == 0 || modeMax < max) {
+		max = modeMax
+	}
+	return min, max
+}
+
+// convert a device temperature into degrees celsius
+func scaled(v int) float64 {
+	return float64(v) / temperatureScale
+}
+
+func formatLimit(v float64) string {
+	if v == 0 {
+		return "any"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,91 @@
+package hvac
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// a device supporting auto, heat & cool with three fan speeds, in cool mode
+func testStatus() *HVACStatus {
+	s := &HVACStatus{}
+	s.Status.Power = "on"
+	s.Status.Mode = "cool"
+	s.Status.ConfigModeMap = 1 | 2 | 16
+	s.Status.ConfigFanMap.Num0 = "auto"
+	s.Status.ConfigFanMap.Num1 = "low"
+	s.Status.ConfigFanMap.Num2 = "high"
+	s.Status.SetpointMin = 160
+	s.Status.SetpointMax = 300
+	s.Status.CoolTemperatureMin = 180
+	s.Status.CoolTemperatureMax = 280
+	s.Status.HeatTemperatureMin = 170
+	return s
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(s *HVACStatus)
+		key    string
+		value  string
+		valid  bool
+	}{
+		{"power on", nil, "power", "on", true},
+		{"power is case insensitive", nil, "power", "OFF", true},
+		{"power standby", nil, "power", "standby", false},
+		{"quiet mode", nil, "quiet_mode", "off", true},
+		{"quiet mode level", nil, "quiet_mode", "2", false},
+		{"supported mode", nil, "mode", "heat", true},
+		{"unsupported mode", nil, "mode", "dry", false},
+		{"restricted mode", func(s *HVACStatus) { s.Status.RuntimeModeRestrictions = 2 }, "mode", "heat", false},
+		{"no reported modes", func(s *HVACStatus) { s.Status.ConfigModeMap = 0 }, "mode", "dry", true},
+		{"fan speed index", nil, "fan_speed", "1", true},
+		{"fan speed name", nil, "fan_speed", "High", true},
+		{"unmapped fan speed", nil, "fan_speed", "3", false},
+		{"setpoint at the cool min", nil, "setpoint", "18", true},
+		{"setpoint at the cool max", nil, "setpoint", "28", true},
+		{"setpoint below the cool min", nil, "setpoint", "17.9", false},
+		{"setpoint above the cool max", nil, "setpoint", "28.5", false},
+		{"setpoint at the heat min", func(s *HVACStatus) { s.Status.Mode = "heat" }, "setpoint", "17", true},
+		{"setpoint below the heat min", func(s *HVACStatus) { s.Status.Mode = "heat" }, "setpoint", "16.5", false},
+		{"setpoint at the overall max in heat", func(s *HVACStatus) { s.Status.Mode = "heat" }, "setpoint", "30", true},
+		{"setpoint above the overall max in heat", func(s *HVACStatus) { s.Status.Mode = "heat" }, "setpoint", "30.5", false},
+		{"setpoint at the overall min in auto", func(s *HVACStatus) { s.Status.Mode = "auto" }, "setpoint", "16", true},
+		{"setpoint below the overall min in auto", func(s *HVACStatus) { s.Status.Mode = "auto" }, "setpoint", "15.5", false},
+		{"mode limits looser than the overall", func(s *HVACStatus) { s.Status.CoolTemperatureMax = 320 }, "setpoint", "30.5", false},
+		{"setpoint without limits", func(s *HVACStatus) { *s = HVACStatus{} }, "setpoint", "5", true},
+		{"setpoint not a number", nil, "setpoint", "warm", false},
+		{"key without limits", nil, "vvane", "swing", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := testStatus()
+			if tt.modify != nil {
+				tt.modify(s)
+			}
+			err := s.Validate(tt.key, tt.value)
+			if tt.valid && err != nil {
+				t.Errorf("Validate(%s, %s) = %v, want nil", tt.key, tt.value, err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidValue) {
+				t.Errorf("Validate(%s, %s) = %v, want %v", tt.key, tt.value, err, ErrInvalidValue)
+			}
+		})
+	}
+}
+
+func TestModes(t *testing.T) {
+	s := testStatus()
+	s.Status.RuntimeModeRestrictions = 1
+	if got, want := s.Modes(), []string{"heat", "cool"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Modes() = %v, want %v", got, want)
+	}
+}
+
+func TestFanSpeeds(t *testing.T) {
+	want := []string{"0", "1", "2", "auto", "high", "low"}
+	if got := testStatus().FanSpeeds(); !reflect.DeepEqual(got, want) {
+		t.Errorf("FanSpeeds() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nullify005/chat-hvac/pkg/hvac"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace string = "chat_hvac"
+
+// the modes reported as a state set, one series per mode
+var modes = []string{"auto", "heat", "dry", "fan", "cool"}
+
+// the bot & device metrics exposed in the prometheus exposition format. a nil
+// Metrics is valid & records nothing
+type Metrics struct {
+	registry       *prometheus.Registry
+	events         *prometheus.CounterVec
+	commands       *prometheus.CounterVec
+	latency        *prometheus.HistogramVec
+	upstreamErrors *prometheus.CounterVec
+	temperature    *prometheus.GaugeVec
+	setpoint       *prometheus.GaugeVec
+	power          *prometheus.GaugeVec
+	mode           *prometheus.GaugeVec
+	fanSpeed       *prometheus.GaugeVec
+	rssi           *prometheus.GaugeVec
+	workingHours   *prometheus.GaugeVec
+	errorCode      *prometheus.GaugeVec
+}
+
+func New() *Metrics {
+	device := []string{"device"}
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "events_received_total", Help: "Events received from the chat adapter by type.",
+		}, []string{"type"}),
+		commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "commands_total", Help: "Commands handled by verb.",
+		}, []string{"verb"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "handler_duration_seconds", Help: "Time taken to handle a command by verb.",
+			Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+		}, []string{"verb"}),
+		upstreamErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "upstream_errors_total", Help: "Failed requests to the hvac api by status code, or transport.",
+		}, []string{"method", "code"}),
+		temperature:  gauge("temperature_celsius", "The room temperature.", device),
+		setpoint:     gauge("setpoint_celsius", "The target temperature.", device),
+		power:        gauge("power", "Whether the device is on.", device),
+		mode:         gauge("mode", "The current mode of the device, 1 for the active mode.", []string{"device", "mode"}),
+		fanSpeed:     gauge("fan_speed", "The fan speed index.", device),
+		rssi:         gauge("rssi_dbm", "The wifi signal strength of the device.", device),
+		workingHours: gauge("working_hours", "The hours the device has been running.", device),
+		errorCode:    gauge("error_code", "The error code reported by the device, 0 when healthy.", device),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.events, m.commands, m.latency, m.upstreamErrors,
+		m.temperature, m.setpoint, m.power, m.mode, m.fanSpeed, m.rssi, m.workingHours, m.errorCode,
+	)
+	return m
+}
+
+func gauge(name, help string, labels []string) *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: namespace, Name: name, Help: help}, labels)
+}
+
+// serves the metrics in the prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// count an event received from the adapter
+func (m *Metrics) Event(eventType string) {
+	if m == nil {
+		return
+	}
+	m.events.WithLabelValues(eventType).Inc()
+}
+
+// count a command & observe how long its handler took
+func (m *Metrics) Command(verb string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.commands.WithLabelValues(verb).Inc()
+	m.latency.WithLabelValues(verb).Observe(d.Seconds())
+}
+
+// the hvac observer counting failed api requests
+func (m *Metrics) Upstream() hvac.Observer {
+	return func(method string, err error) {
+		if m == nil || err == nil {
+			return
+		}
+		code := "transport"
+		var statusErr *hvac.StatusError
+		if errors.As(err, &statusErr) {
+			code = strconv.Itoa(statusErr.Code)
+		}
+		m.upstreamErrors.WithLabelValues(strings.ToLower(method), code).Inc()
+	}
+}
+
+// the hvac status observer recording the device gauges from every status
+// fetched, so they're kept without polling too
+func (m *Metrics) Device(device string) hvac.StatusObserver {
+	return func(s *hvac.HVACStatus) {
+		if m == nil {
+			return
+		}
+		m.temperature.WithLabelValues(device).Set(s.RoomTemperature())
+		m.setpoint.WithLabelValues(device).Set(s.SetpointTemperature())
+		m.power.WithLabelValues(device).Set(boolean(strings.EqualFold(s.Status.Power, "on")))
+		for _, mode := range modes {
+			m.mode.WithLabelValues(device, mode).Set(boolean(strings.EqualFold(s.Status.Mode, mode)))
+		}
+		m.fanSpeed.WithLabelValues(device).Set(float64(s.Status.FanSpeed))
+		m.rssi.WithLabelValues(device).Set(float64(s.Status.Rssi))
+		m.workingHours.WithLabelValues(device).Set(float64(s.Status.WorkingHours))
+		m.errorCode.WithLabelValues(device).Set(float64(s.Status.ErrorCode))
+	}
+}
+
+func boolean(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
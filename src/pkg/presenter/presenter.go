@@ -0,0 +1,452 @@
+package presenter
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nullify005/chat-hvac/pkg/adapter"
+	"github.com/nullify005/chat-hvac/pkg/alert"
+	"github.com/nullify005/chat-hvac/pkg/audit"
+	"github.com/nullify005/chat-hvac/pkg/history"
+	"github.com/nullify005/chat-hvac/pkg/hvac"
+	"github.com/nullify005/chat-hvac/pkg/scheduler"
+)
+
+// how times are shown in chat
+const timeFormat string = "Mon 2 Jan 15:04 MST"
+
+// formats a curated summary of the device status for chat
+func Status(s *hvac.HVACStatus) string {
+	lines := []string{
+		fmt.Sprintf("%s power: %s", powerIcon(s.Status.Power), s.Status.Power),
+		fmt.Sprintf(":gear: mode: %s", s.Status.Mode),
+		fmt.Sprintf(":dart: setpoint: %s", Temperature(s.SetpointTemperature())),
+		fmt.Sprintf(":thermometer: room: %s", Temperature(s.RoomTemperature())),
+		fmt.Sprintf(":dash: fan: %s", s.FanSpeedName()),
+		fmt.Sprintf(":arrow_up_down: vane: %s", s.Status.Vvane),
+	}
+	if s.Status.FilterClean != 0 {
+		lines = append(lines, ":warning: filter: needs cleaning")
+	} else {
+		lines = append(lines, ":white_check_mark: filter: ok")
+	}
+	if s.Status.ErrorCode != 0 {
+		lines = append(lines, fmt.Sprintf(":rotating_light: error: code %d address %d", s.Status.ErrorCode, s.Status.ErrorAddress))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formats every field of the device status for chat
+func StatusFull(s *hvac.HVACStatus) string {
+	return s.String()
+}
+
+// formats a temperature in degrees celsius
+func Temperature(t float64) string {
+	return fmt.Sprintf("%.1f°C", t)
+}
+
+func powerIcon(power string) string {
+	if strings.EqualFold(power, "on") {
+		return ":large_green_circle:"
+	}
+	return ":red_circle:"
+}
+
+// formats a single status key & value for chat
+func Field(key string, value interface{}) string {
+	return fmt.Sprintf("%s: %v", key, value)
+}
+
+// formats the outcome of a set for chat
+func Set(r *hvac.SetResult) string {
+	return fmt.Sprintf(":+1: `%s`", r.Response)
+}
+
+// formats an error returned from the hvac client for chat
+func Error(err error) string {
+	var (
+		statusErr *hvac.StatusError
+		decodeErr *hvac.DecodeError
+	)
+	switch {
+	case errors.As(err, &statusErr):
+		return fmt.Sprintf(":x: the hvac api responded with %d: `%s`", statusErr.Code, statusErr.Body)
+	case errors.As(err, &decodeErr):
+		return fmt.Sprintf(":x: unable to understand the hvac api response: `%s`", decodeErr.Body)
+	case errors.Is(err, hvac.ErrTransport):
+		return fmt.Sprintf(":x: unable to reach the hvac api. cause: %v", errors.Unwrap(err))
+	}
+	return fmt.Sprintf(":x: %v", err)
+}
+
+// labels a reply with the name of the device it came from
+func Device(name, reply string) string {
+	return fmt.Sprintf("*%s*\n%s", name, reply)
+}
+
+// builds an interactive control panel from the device status, notice is the
+// outcome of the last action if any
+func Panel(device string, s *hvac.HVACStatus, notice string) *adapter.Panel {
+	return &adapter.Panel{
+		Device:      device,
+		Power:       s.Status.Power,
+		Mode:        s.Status.Mode,
+		Modes:       s.Modes(),
+		FanSpeed:    s.FanSpeedName(),
+		FanSpeeds:   fanNames(s),
+		Setpoint:    s.SetpointTemperature(),
+		Temperature: s.RoomTemperature(),
+		Notice:      notice,
+	}
+}
+
+// the plain text fallback for a control panel
+func PanelText(p *adapter.Panel) string {
+	text := fmt.Sprintf("*%s* power: %s mode: %s setpoint: %s room: %s fan: %s",
+		p.Device, p.Power, p.Mode, Temperature(p.Setpoint), Temperature(p.Temperature), p.FanSpeed)
+	if p.Notice != "" {
+		text += "\n" + p.Notice
+	}
+	return text
+}
+
+// the fan speed names in index order
+func fanNames(s *hvac.HVACStatus) []string {
+	fans := s.FanMap()
+	indexes := []string{}
+	for index := range fans {
+		indexes = append(indexes, index)
+	}
+	sort.Strings(indexes)
+	names := []string{}
+	for _, index := range indexes {
+		names = append(names, fans[index])
+	}
+	return names
+}
+
+// formats a single schedule
+func Schedule(s scheduler.Schedule) string {
+	settings := []string{}
+	for _, set := range s.Settings {
+		settings = append(settings, fmt.Sprintf("%s=%s", set.Key, set.Value))
+	}
+	text := fmt.Sprintf("`%s` `%s` set %s %s", s.ID, s.Spec, s.Device, strings.Join(settings, " "))
+	if !s.Next.IsZero() {
+		text += fmt.Sprintf(" next: %s", s.Next.Format(timeFormat))
+	}
+	return text
+}
+
+// formats every schedule
+func Schedules(list []scheduler.Schedule) string {
+	if len(list) == 0 {
+		return "There are no schedules. :calendar:"
+	}
+	lines := []string{}
+	for _, s := range list {
+		lines = append(lines, Schedule(s))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formats the outcome of a schedule run
+func ScheduleRun(s scheduler.Schedule, outcome string) string {
+	return fmt.Sprintf(":alarm_clock: schedule `%s` `%s` ran\n%s", s.ID, s.Spec, outcome)
+}
+
+// formats a single pending timer
+func Timer(t scheduler.Timer) string {
+	settings := []string{}
+	for _, set := range t.Settings {
+		settings = append(settings, fmt.Sprintf("%s=%s", set.Key, set.Value))
+	}
+	return fmt.Sprintf("`%s` at %s set %s %s", t.ID, t.At.Format(timeFormat), t.Device, strings.Join(settings, " "))
+}
+
+// formats every pending timer
+func Timers(list []scheduler.Timer) string {
+	if len(list) == 0 {
+		return "There are no pending timers. :hourglass:"
+	}
+	lines := []string{}
+	for _, t := range list {
+		lines = append(lines, Timer(t))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formats the outcome of a timer firing
+func TimerRun(t scheduler.Timer, outcome string) string {
+	return fmt.Sprintf(":alarm_clock: <@%s> your timer `%s` went off\n%s", t.User, t.ID, outcome)
+}
+
+// formats a duration to the minute, or to the second when under a minute
+func Duration(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+	text := strings.TrimSuffix(d.Round(time.Minute).String(), "0s")
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return text
+}
+
+// formats the outcome of each setting applied together
+func Changes(changes []hvac.Change) string {
+	lines := []string{}
+	for _, c := range changes {
+		switch c.Outcome {
+		case hvac.Applied:
+			lines = append(lines, fmt.Sprintf(":+1: `%s` %s → %s", c.Key, c.Previous, c.Value))
+		case hvac.Unchanged:
+			lines = append(lines, fmt.Sprintf(":white_check_mark: `%s` already %s", c.Key, c.Value))
+		case hvac.Failed:
+			lines = append(lines, fmt.Sprintf("%s (`%s` %s)", Error(c.Err), c.Key, c.Value))
+		case hvac.Skipped:
+			lines = append(lines, fmt.Sprintf(":fast_forward: `%s` %s skipped", c.Key, c.Value))
+		case hvac.RolledBack:
+			lines = append(lines, fmt.Sprintf(":leftwards_arrow_with_hook: `%s` rolled back to %s", c.Key, c.Previous))
+		case hvac.RollbackFailed:
+			lines = append(lines, fmt.Sprintf(":warning: `%s` left at %s, rolling back to %s failed: %v", c.Key, c.Value, c.Previous, c.Err))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formats a named preset & its settings
+func Preset(name string, settings []hvac.Setting) string {
+	pairs := []string{}
+	for _, s := range settings {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", s.Key, s.Value))
+	}
+	return fmt.Sprintf("`%s` %s", name, strings.Join(pairs, " "))
+}
+
+// formats a change observed by the poller
+func Notification(e hvac.Event) string {
+	switch e.Type {
+	case hvac.PowerChanged:
+		emoji := ":zzz:"
+		if e.To == "on" {
+			emoji = ":zap:"
+		}
+		return fmt.Sprintf("%s power turned %s", emoji, e.To)
+	case hvac.ModeChanged:
+		return fmt.Sprintf(":arrows_counterclockwise: mode changed from %s to %s", e.From, e.To)
+	case hvac.SetpointChanged:
+		return fmt.Sprintf(":thermometer: setpoint changed from %s°C to %s°C", e.From, e.To)
+	case hvac.FanSpeedChanged:
+		return fmt.Sprintf(":dash: fan speed changed from %s to %s", e.From, e.To)
+	case hvac.ErrorRaised:
+		return fmt.Sprintf(":rotating_light: the unit reported error code %s", e.To)
+	case hvac.ErrorCleared:
+		return fmt.Sprintf(":white_check_mark: error code %s has cleared", e.From)
+	}
+	return fmt.Sprintf("%s changed from %s to %s", e.Type, e.From, e.To)
+}
+
+// formats an alert as it's raised, reminded or resolved
+func Alert(a alert.Alert, s alert.State) string {
+	detail := alertDetail(a)
+	switch s {
+	case alert.Raised:
+		return fmt.Sprintf(":rotating_light: alert `%s` %s. reply `@hvac ack %s` to silence reminders", a.ID, detail, a.ID)
+	case alert.Reminder:
+		return fmt.Sprintf(":rotating_light: alert `%s` is still active since %s, %s", a.ID, a.Raised.Format(timeFormat), detail)
+	case alert.Resolved:
+		return fmt.Sprintf(":white_check_mark: alert `%s` resolved, %s", a.ID, detail)
+	}
+	return detail
+}
+
+// formats every active alert
+func Alerts(list []alert.Alert) string {
+	if len(list) == 0 {
+		return "There are no active alerts. :white_check_mark:"
+	}
+	lines := []string{}
+	for _, a := range list {
+		line := fmt.Sprintf("`%s` *%s* %s since %s", a.ID, a.Device, alertDetail(a), a.Raised.Format(timeFormat))
+		if a.Acked {
+			line += fmt.Sprintf(", acknowledged by <@%s>", a.AckedBy)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// the description of an alert, including the error code of a fault
+func alertDetail(a alert.Alert) string {
+	if a.Kind != alert.Fault || a.Code == 0 {
+		return a.Detail
+	}
+	detail := fmt.Sprintf("error %d: %s", a.Code, a.Detail)
+	if a.Address != 0 {
+		detail += fmt.Sprintf(" (unit address %d)", a.Address)
+	}
+	return detail
+}
+
+// summarises the temperature & setpoint of a device over a period
+func History(label string, temperature, setpoint history.Summary) string {
+	return strings.Join([]string{
+		fmt.Sprintf("History for %s, %d samples", label, temperature.Samples),
+		fmt.Sprintf(":thermometer: %s avg, %s min at %s, %s max at %s",
+			Temperature(temperature.Mean),
+			Temperature(temperature.Min), temperature.MinAt.Format(timeFormat),
+			Temperature(temperature.Max), temperature.MaxAt.Format(timeFormat)),
+		fmt.Sprintf("%s %s → %s since %s", trendIcon(temperature), Temperature(temperature.First.Temperature), Temperature(temperature.Last.Temperature), temperature.First.Time.Format(timeFormat)),
+		fmt.Sprintf(":dart: setpoint %s avg, %s to %s", Temperature(setpoint.Mean), Temperature(setpoint.Min), Temperature(setpoint.Max)),
+		fmt.Sprintf(":electric_plug: on %.0f%% of the time, currently %s", temperature.PowerOn*100, sampleMode(temperature.Last)),
+	}, "\n")
+}
+
+// formats the average, minimum or maximum of a field over a period
+func Statistic(stat, label string, s history.Summary) string {
+	switch stat {
+	case "min":
+		return fmt.Sprintf("The minimum %s %s was %s at %s", s.Field, label, Temperature(s.Min), s.MinAt.Format(timeFormat))
+	case "max":
+		return fmt.Sprintf("The maximum %s %s was %s at %s", s.Field, label, Temperature(s.Max), s.MaxAt.Format(timeFormat))
+	}
+	return fmt.Sprintf("The average %s %s was %s over %d samples", s.Field, label, Temperature(s.Mean), s.Samples)
+}
+
+// the power & mode of a sample
+func sampleMode(s history.Sample) string {
+	if !s.Power {
+		return "off"
+	}
+	return fmt.Sprintf("on in %s", s.Mode)
+}
+
+func trendIcon(s history.Summary) string {
+	if s.Last.Temperature < s.First.Temperature {
+		return ":chart_with_downwards_trend:"
+	}
+	return ":chart_with_upwards_trend:"
+}
+
+// formats the sparklines of the temperature & setpoint over a period
+func Sparklines(label, temperature, setpoint string, lo, hi float64) string {
+	return strings.Join([]string{
+		fmt.Sprintf("Temperature %s, %s to %s", label, Temperature(lo), Temperature(hi)),
+		fmt.Sprintf("`%s` temperature", temperature),
+		fmt.Sprintf("`%s` setpoint", setpoint),
+	}, "\n")
+}
+
+// formats the band of a thermostat & its last decision
+func Thermostat(b hvac.Band, enabled bool, last hvac.Decision) string {
+	if !enabled {
+		return ":thermometer: the thermostat is off"
+	}
+	text := fmt.Sprintf(":thermometer: keeping the room between %s & %s", Temperature(b.Low), Temperature(b.High))
+	if last.Time.IsZero() {
+		return text
+	}
+	text += fmt.Sprintf("\nlast checked %s at %s, %s: %s", last.Time.Format(timeFormat), Temperature(last.Temperature), last.Action, last.Reason)
+	if last.Err != nil {
+		text += "\n" + Error(last.Err)
+	}
+	return text
+}
+
+// formats a time as it's shown in chat
+func Time(t time.Time) string {
+	return t.Format(timeFormat)
+}
+
+// formats away mode, when away until the return & when the devices are restored
+func Away(away bool, until, restore time.Time, user string) string {
+	if !away {
+		return ":house: the house isn't in away mode"
+	}
+	text := fmt.Sprintf(":airplane: away until %s, set by <@%s>", Time(until), user)
+	if restore.Before(until) {
+		text += fmt.Sprintf(", restoring early at %s to precondition the house", Time(restore))
+	}
+	return text
+}
+
+// the outcome of a schedule or timer skipped while away
+func AwaySkipped(until time.Time) string {
+	return fmt.Sprintf(":fast_forward: skipped, the house is away until %s", Time(until))
+}
+
+// formats audit records, newest first
+func Audit(records []audit.Record) string {
+	if len(records) == 0 {
+		return "Nothing has been audited yet. :ledger:"
+	}
+	lines := []string{}
+	for _, r := range records {
+		line := fmt.Sprintf("%s %s `%s`", r.Time.Format(timeFormat), actor(r), r.Command.Raw)
+		if r.Device != "" {
+			line += fmt.Sprintf(" on *%s*", r.Device)
+		}
+		changes := []string{}
+		for _, c := range r.Changes {
+			changes = append(changes, auditChange(c))
+		}
+		if len(changes) > 0 {
+			line += ": " + strings.Join(changes, ", ")
+		}
+		if r.Error != "" {
+			line += fmt.Sprintf(" :x: %s", r.Error)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formats who changed key in the records, newest first
+func WhoChanged(key string, records []audit.Record) string {
+	if len(records) == 0 {
+		return fmt.Sprintf("No one has changed `%s` yet. :ledger:", key)
+	}
+	lines := []string{}
+	for _, r := range records {
+		for _, c := range r.Changes {
+			if c.Key != key {
+				continue
+			}
+			line := fmt.Sprintf("%s changed `%s` from %s to %s on *%s* at %s", actor(r), key, c.Before, c.After, r.Device, r.Time.Format(timeFormat))
+			if r.Command.Verb != audit.External {
+				line += fmt.Sprintf(" via `%s`", r.Command.Raw)
+			}
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// who or what made an audited change
+func actor(r audit.Record) string {
+	switch {
+	case r.Command.Verb == audit.External:
+		return "someone outside of chat"
+	case r.Command.Verb == audit.Thermostat:
+		return "the thermostat"
+	case r.User == "":
+		return "someone"
+	}
+	return fmt.Sprintf("<@%s>", r.User)
+}
+
+func auditChange(c audit.Change) string {
+	text := fmt.Sprintf("`%s` → %s", c.Key, c.After)
+	if c.Before != "" {
+		text = fmt.Sprintf("`%s` %s → %s", c.Key, c.Before, c.After)
+	}
+	if c.Outcome != hvac.Applied.String() {
+		text += fmt.Sprintf(" (%s)", strings.ReplaceAll(c.Outcome, "_", " "))
+	}
+	return text
+}
@@ -0,0 +1,71 @@
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nullify005/chat-hvac/pkg/adapter"
+	"github.com/nullify005/chat-hvac/pkg/alert"
+	"github.com/nullify005/chat-hvac/pkg/presenter"
+)
+
+// raises alerts from the polled status of each device to the configured
+// channel, alerting requires polling to be enabled
+func WithAlerter(a *alert.Alerter) ReceiverOption {
+	return func(r *Receiver) {
+		r.alerter = a
+	}
+}
+
+// post an alert as it's raised, reminded or resolved
+func (r *Receiver) notifyAlert(a alert.Alert, s alert.State) {
+	if r.channel == "" {
+		return
+	}
+	text := presenter.Alert(a, s)
+	if len(r.devices) > 1 {
+		text = presenter.Device(a.Device, text)
+	}
+	r.adapter.Say(adapter.Message{Text: text, Channel: r.channel})
+}
+
+// list the active alerts
+func alertsHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	reply := "Alerting isn't enabled, it needs polling. :rotating_light:"
+	if r.alerter != nil && len(r.pollers) > 0 {
+		reply = presenter.Alerts(r.alerter.Active())
+	}
+	r.reply(e, reply)
+}
+
+// acknowledge an alert, or every active alert, to silence its reminders
+func ackHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	var reply string
+	switch acked, err := r.ack(c.Arg(0), e.User); {
+	case r.alerter == nil || len(r.pollers) == 0:
+		reply = "Alerting isn't enabled, it needs polling. :rotating_light:"
+	case err != nil:
+		reply = presenter.Error(err)
+	case len(acked) == 0:
+		reply = "There are no alerts to acknowledge. :white_check_mark:"
+	default:
+		ids := []string{}
+		for _, a := range acked {
+			ids = append(ids, fmt.Sprintf("`%s`", a.ID))
+		}
+		r.logger.Printf("acknowledged alerts: %s user: %s", strings.Join(ids, ","), e.User)
+		reply = fmt.Sprintf(":mute: acknowledged %s, I'll let you know when it resolves", strings.Join(ids, ", "))
+	}
+	r.reply(e, reply)
+}
+
+func (r *Receiver) ack(id, user string) ([]alert.Alert, error) {
+	if r.alerter == nil {
+		return nil, nil
+	}
+	if strings.EqualFold(id, allDevices) {
+		id = ""
+	}
+	return r.alerter.Ack(id, user)
+}
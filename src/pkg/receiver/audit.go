@@ -0,0 +1,148 @@
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nullify005/chat-hvac/pkg/adapter"
+	"github.com/nullify005/chat-hvac/pkg/audit"
+	"github.com/nullify005/chat-hvac/pkg/hvac"
+	"github.com/nullify005/chat-hvac/pkg/presenter"
+)
+
+const (
+	auditUsage string = "[n]"
+	whoUsage   string = "changed <key> [device]"
+	// the records shown when no number is given & the most which can be
+	defaultAuditRecords int = 10
+	maxAuditRecords     int = 50
+	// the changes shown by who changed
+	whoRecords int = 5
+)
+
+// records every command which changed a device & changes made outside of chat
+func WithAudit(l *audit.Log) ReceiverOption {
+	return func(r *Receiver) {
+		r.audit = l
+	}
+}
+
+// record what a command did to the target on behalf of the user behind the
+// event, commands which don't target a device such as shutdown leave it empty
+func (r *Receiver) record(e *adapter.Event, c *Command, t target, changes []audit.Change, err error) {
+	if r.audit == nil {
+		return
+	}
+	command := audit.Command{Verb: c.Verb, Args: c.Args, Params: c.Params, Raw: c.Raw}
+	r.write(e, command, t.name, changes, err)
+}
+
+// record a change the poller observed which wasn't made by us
+func (r *Receiver) recordExternal(name string, e hvac.Event) {
+	if r.audit == nil {
+		return
+	}
+	key := string(e.Type)
+	if e.Type == hvac.ErrorRaised || e.Type == hvac.ErrorCleared {
+		key = "error_code"
+	}
+	r.write(&adapter.Event{}, audit.Command{Verb: audit.External, Raw: audit.External}, name, []audit.Change{{
+		Key:     key,
+		Before:  e.From,
+		After:   e.To,
+		Outcome: hvac.Applied.String(),
+	}}, nil)
+}
+
+// the thermostat subscriber which audits the changes it makes to the device
+// named name
+func (r *Receiver) recordDecision(name string) hvac.DecisionSubscriber {
+	return func(d hvac.Decision) {
+		command := &Command{
+			Verb: audit.Thermostat,
+			Args: []string{string(d.Action)},
+			Raw:  fmt.Sprintf("%s %s: %s", audit.Thermostat, d.Action, d.Reason),
+		}
+		r.record(&adapter.Event{}, command, target{name: name, hvac: r.devices[name]}, audit.Changes(d.Changes), d.Err)
+	}
+}
+
+// append a record of what the command did to the device
+func (r *Receiver) write(e *adapter.Event, command audit.Command, device string, changes []audit.Change, err error) {
+	rec := audit.Record{
+		Time:    time.Now(),
+		User:    e.User,
+		Channel: e.Channel,
+		Command: command,
+		Device:  device,
+		Changes: changes,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	if err := r.audit.Write(rec); err != nil {
+		r.logger.Printf("unable to write audit record: %+v cause: %v", rec, err)
+	}
+}
+
+// the most recent audit records, newest first
+func auditHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	if r.audit == nil {
+		r.reply(e, "Auditing isn't enabled, it needs a stateDir. :ledger:")
+		return
+	}
+	n := defaultAuditRecords
+	if c.Arg(0) != "" {
+		v, err := strconv.Atoi(c.Arg(0))
+		if err != nil || v < 1 {
+			usageHandler(ctx, r, c, e)
+			return
+		}
+		n = v
+	}
+	if n > maxAuditRecords {
+		n = maxAuditRecords
+	}
+	records, err := r.audit.Recent(n, nil)
+	reply := presenter.Error(err)
+	if err == nil {
+		reply = presenter.Audit(r.localRecords(records))
+	}
+	r.reply(e, reply)
+}
+
+// the most recent changes to a key, optionally of a single device
+func whoHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	if r.audit == nil {
+		r.reply(e, "Auditing isn't enabled, it needs a stateDir. :ledger:")
+		return
+	}
+	if strings.ToLower(c.Arg(0)) != "changed" || len(c.Args) < 2 {
+		usageHandler(ctx, r, c, e)
+		return
+	}
+	key, device := strings.ToLower(c.Args[1]), c.Arg(2)
+	if device != "" && !r.isTarget(device) {
+		parseErrorHandler(ctx, r, fmt.Errorf("unknown device: `%s`, choose one of: %s", device, strings.Join(r.deviceNames(), ", ")), e)
+		return
+	}
+	records, err := r.audit.Recent(whoRecords, func(rec audit.Record) bool {
+		return rec.Changed(key) && (device == "" || device == allDevices || rec.Device == device)
+	})
+	reply := presenter.Error(err)
+	if err == nil {
+		reply = presenter.WhoChanged(key, r.localRecords(records))
+	}
+	r.reply(e, reply)
+}
+
+// the records with their times in the receiver's location
+func (r *Receiver) localRecords(records []audit.Record) []audit.Record {
+	for i := range records {
+		records[i].Time = records[i].Time.In(r.location)
+	}
+	return records
+}
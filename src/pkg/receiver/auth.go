@@ -0,0 +1,138 @@
+package receiver
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nullify005/chat-hvac/pkg/adapter"
+)
+
+// the level of access required for a verb, each role includes the ones below it
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleViewer
+	RoleOperator
+	RoleAdmin
+)
+
+// how long the members of a user group are cached for
+const groupCacheTTL time.Duration = 5 * time.Minute
+
+var roleNames = map[Role]string{
+	RoleNone:     "none",
+	RoleViewer:   "viewer",
+	RoleOperator: "operator",
+	RoleAdmin:    "admin",
+}
+
+func (r Role) String() string {
+	return roleNames[r]
+}
+
+// parse a role name from config
+func ParseRole(name string) (Role, error) {
+	for role, n := range roleNames {
+		if strings.EqualFold(name, n) && role != RoleNone {
+			return role, nil
+		}
+	}
+	return RoleNone, fmt.Errorf("unknown role: %s expected one of: viewer, operator, admin", name)
+}
+
+// the users, user groups & channels which are granted a role. anyone within a
+// granted channel holds the role
+type Grant struct {
+	Users    []string
+	Groups   []string
+	Channels []string
+}
+
+// resolves the role of the user behind an event
+type authorizer struct {
+	logger   *log.Logger
+	grants   map[Role]Grant
+	resolver adapter.GroupResolver
+	mu       sync.Mutex
+	groups   map[string]cachedGroup
+}
+
+type cachedGroup struct {
+	members []string
+	fetched time.Time
+}
+
+func newAuthorizer() *authorizer {
+	return &authorizer{
+		grants: map[Role]Grant{},
+		groups: map[string]cachedGroup{},
+	}
+}
+
+// authorization is only enforced once a role has been granted
+func (a *authorizer) enabled() bool {
+	return len(a.grants) > 0
+}
+
+// the highest role held by the user within the channel
+func (a *authorizer) role(user, channel string) Role {
+	if !a.enabled() {
+		return RoleAdmin
+	}
+	for _, role := range []Role{RoleAdmin, RoleOperator, RoleViewer} {
+		g, ok := a.grants[role]
+		if !ok {
+			continue
+		}
+		if contains(g.Users, user) || contains(g.Channels, channel) || a.inGroups(g.Groups, user) {
+			return role
+		}
+	}
+	return RoleNone
+}
+
+// whether the user is a member of any of the groups
+func (a *authorizer) inGroups(groups []string, user string) bool {
+	for _, group := range groups {
+		members, err := a.members(group)
+		if err != nil {
+			a.logger.Printf("unable to resolve members of group: %s cause: %v", group, err)
+			continue
+		}
+		if contains(members, user) {
+			return true
+		}
+	}
+	return false
+}
+
+// the members of a group, cached for groupCacheTTL
+func (a *authorizer) members(group string) ([]string, error) {
+	if a.resolver == nil {
+		return nil, fmt.Errorf("the adapter can't resolve user groups")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if c, ok := a.groups[group]; ok && time.Since(c.fetched) < groupCacheTTL {
+		return c.members, nil
+	}
+	members, err := a.resolver.GroupMembers(group)
+	if err != nil {
+		return nil, err
+	}
+	a.groups[group] = cachedGroup{members: members, fetched: time.Now()}
+	return members, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,350 @@
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nullify005/chat-hvac/pkg/adapter"
+	"github.com/nullify005/chat-hvac/pkg/audit"
+	"github.com/nullify005/chat-hvac/pkg/hvac"
+	"github.com/nullify005/chat-hvac/pkg/presenter"
+	"github.com/nullify005/chat-hvac/pkg/store"
+)
+
+const awayUsage string = "[until <date> [time] | for <duration> | cancel]"
+
+// the date formats the return may be given in, a date alone is midnight
+var awayFormats = []string{"2006-01-02 15:04", "2006-01-02"}
+
+type awayState string
+
+const (
+	stateHome awayState = "home"
+	stateAway awayState = "away"
+)
+
+// away mode, which snapshots every device & applies an energy saving profile
+// until the return. home -> away when started & away -> home when restored,
+// either on time, early to precondition the house or when cancelled
+type away struct {
+	mu           sync.Mutex
+	path         string
+	profile      []hvac.Setting
+	band         *hvac.Band
+	precondition time.Duration
+	record       awayRecord
+	timer        *time.Timer
+}
+
+// the persisted state of away mode
+type awayRecord struct {
+	State   awayState `json:"state"`
+	Since   time.Time `json:"since,omitempty"`
+	Until   time.Time `json:"until,omitempty"`
+	User    string    `json:"user,omitempty"`
+	Channel string    `json:"channel,omitempty"`
+	// what each device is restored to, keyed by device name
+	Snapshots map[string]awaySnapshot `json:"snapshots,omitempty"`
+}
+
+// the settings of a device & its thermostat band before leaving
+type awaySnapshot struct {
+	Settings []hvac.Setting `json:"settings"`
+	Band     *hvac.Band     `json:"band,omitempty"`
+}
+
+// the settings applied to every device while away, defaults to power off
+// unless a band is kept instead
+func WithAwayProfile(settings map[string]string) ReceiverOption {
+	return func(r *Receiver) {
+		list := []hvac.Setting{}
+		for key, value := range settings {
+			list = append(list, hvac.Setting{Key: key, Value: value})
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+		r.away.profile = list
+	}
+}
+
+// keep every device within a wide band with the thermostat while away,
+// requires polling
+func WithAwayBand(b hvac.Band) ReceiverOption {
+	return func(r *Receiver) {
+		r.away.band = &b
+	}
+}
+
+// how long before the return the devices are restored, so the house is
+// comfortable on arrival
+func WithPrecondition(d time.Duration) ReceiverOption {
+	return func(r *Receiver) {
+		r.away.precondition = d
+	}
+}
+
+// the json file away mode is persisted to, when empty it's lost on restart
+func WithAwayPath(p string) ReceiverOption {
+	return func(r *Receiver) {
+		r.away.path = p
+	}
+}
+
+// load away mode as it was when last persisted
+func (a *away) load() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.record = awayRecord{State: stateHome}
+	if a.path == "" {
+		return nil
+	}
+	return store.Load(a.path, &a.record)
+}
+
+func (a *away) persist() error {
+	if a.path == "" {
+		return nil
+	}
+	return store.Save(a.path, a.record)
+}
+
+// the current state, true when away
+func (a *away) active() (awayRecord, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.record, a.record.State == stateAway
+}
+
+// when the devices are restored
+func (a *away) restoreAt(r awayRecord) time.Time {
+	return r.Until.Add(-a.precondition)
+}
+
+// call fn when the devices are due to be restored, immediately when overdue
+func (a *away) arm(fn func()) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.record.State != stateAway {
+		return
+	}
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+	a.timer = time.AfterFunc(time.Until(a.restoreAt(a.record)), fn)
+}
+
+func (a *away) disarm() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+}
+
+// starting or cancelling away mode requires an operator
+func awayRole(c *Command) Role {
+	switch strings.ToLower(c.Arg(0)) {
+	case "until", "for", "cancel", "off", "home":
+		return RoleOperator
+	}
+	return RoleViewer
+}
+
+// show, start or cancel away mode
+func awayHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	var reply string
+	switch strings.ToLower(c.Arg(0)) {
+	case "", "status":
+		record, _ := r.away.active()
+		reply = presenter.Away(record.State == stateAway, record.Until.In(r.location), r.away.restoreAt(record).In(r.location), record.User)
+	case "until", "for":
+		until, err := parseReturn(c, time.Now().In(r.location))
+		if err != nil {
+			parseErrorHandler(ctx, r, err, e)
+			return
+		}
+		reply = r.leave(ctx, c, until, e)
+	case "cancel", "off", "home":
+		r.away.disarm()
+		r.logger.Printf("away mode cancelled. user: %s", e.User)
+		reply = r.returnHome(ctx, c, e, fmt.Sprintf("<@%s> cancelled away mode", e.User))
+	default:
+		usageHandler(ctx, r, c, e)
+		return
+	}
+	r.reply(e, reply)
+}
+
+// snapshot every device, apply the away profile & arm the return
+func (r *Receiver) leave(ctx context.Context, c *Command, until time.Time, e *adapter.Event) string {
+	if record, ok := r.away.active(); ok {
+		return fmt.Sprintf("The house is already away until %s, `away cancel` first to change it. :airplane:", presenter.Time(record.Until.In(r.location)))
+	}
+	if r.away.band != nil && len(r.thermostats.byName) == 0 {
+		return presenter.Error(fmt.Errorf("the away band needs the thermostat, which needs polling"))
+	}
+	profile := r.away.profile
+	if len(profile) == 0 && r.away.band == nil {
+		profile = []hvac.Setting{{Key: "power", Value: "off"}}
+	}
+	var mu sync.Mutex
+	snapshots := map[string]awaySnapshot{}
+	targets, _ := r.targets(allDevices, e.Channel)
+	reply := r.fanOut(ctx, targets, func(ctx context.Context, t target) string {
+		status, err := t.hvac.Status(ctx)
+		if err != nil {
+			r.logger.Printf("away snapshot failed. device: %s cause: %v", t.name, err)
+			return presenter.Error(err)
+		}
+		snapshot := awaySnapshot{Settings: append([]hvac.Setting{{Key: "power", Value: status.Status.Power}}, status.Snapshot()...)}
+		if th, ok := r.thermostats.byName[t.name]; ok {
+			if b, enabled := th.Band(); enabled {
+				snapshot.Band = &b
+			}
+			th.Disable()
+			if r.away.band != nil {
+				if err := th.Enable(*r.away.band); err != nil {
+					r.logger.Printf("unable to enable the away band. device: %s cause: %v", t.name, err)
+				}
+			}
+		}
+		mu.Lock()
+		snapshots[t.name] = snapshot
+		mu.Unlock()
+		if len(profile) == 0 {
+			return fmt.Sprintf(":thermometer: keeping the house between %s & %s", presenter.Temperature(r.away.band.Low), presenter.Temperature(r.away.band.High))
+		}
+		changes, err := t.hvac.Apply(ctx, profile)
+		r.record(e, c, t, audit.Changes(changes), err)
+		if err != nil {
+			r.logger.Printf("away profile failed. device: %s cause: %v", t.name, err)
+		}
+		return presenter.Changes(changes)
+	})
+	if len(snapshots) == 0 {
+		return reply
+	}
+	r.away.mu.Lock()
+	r.away.record = awayRecord{
+		State:     stateAway,
+		Since:     time.Now(),
+		Until:     until,
+		User:      e.User,
+		Channel:   e.Channel,
+		Snapshots: snapshots,
+	}
+	err := r.away.persist()
+	r.away.mu.Unlock()
+	if err != nil {
+		r.logger.Printf("unable to persist away mode. cause: %v", err)
+	}
+	if err := r.thermostats.persist(); err != nil {
+		r.logger.Printf("unable to persist thermostats. cause: %v", err)
+	}
+	r.away.arm(r.returnOnTime)
+	r.logger.Printf("away mode started until: %s user: %s", until, e.User)
+	return fmt.Sprintf(":airplane: away until %s, restoring at %s. only admins can change settings until then\n%s", presenter.Time(until), presenter.Time(r.away.restoreAt(r.away.record).In(r.location)), reply)
+}
+
+// restore the devices when due & post the outcome to where away mode started
+func (r *Receiver) returnOnTime() {
+	ctx, cancel := context.WithTimeout(r.base, r.timeout)
+	defer cancel()
+	record, ok := r.away.active()
+	if !ok {
+		return
+	}
+	reason := "welcome home"
+	if r.away.precondition > 0 {
+		reason = fmt.Sprintf("preconditioning the house ahead of the return at %s", presenter.Time(record.Until.In(r.location)))
+	}
+	channel := r.channel
+	if channel == "" {
+		channel = record.Channel
+	}
+	command := &Command{Verb: "away", Args: []string{"return"}, Raw: "away return"}
+	r.adapter.Say(adapter.Message{Text: r.returnHome(ctx, command, &adapter.Event{User: record.User, Channel: record.Channel}, reason), Channel: channel})
+}
+
+// restore every device to its snapshot & return home, devices which fail
+// are reported but don't hold up the return. only the first of a cancel &
+// the timer racing restores
+func (r *Receiver) returnHome(ctx context.Context, c *Command, e *adapter.Event, reason string) string {
+	r.away.mu.Lock()
+	record := r.away.record
+	if record.State != stateAway {
+		r.away.mu.Unlock()
+		return "The house isn't in away mode. :house:"
+	}
+	r.away.record = awayRecord{State: stateHome}
+	err := r.away.persist()
+	r.away.mu.Unlock()
+	if err != nil {
+		r.logger.Printf("unable to persist away mode. cause: %v", err)
+	}
+	targets := []target{}
+	for _, name := range r.deviceNames() {
+		if _, ok := record.Snapshots[name]; ok {
+			targets = append(targets, target{name: name, hvac: r.devices[name]})
+		}
+	}
+	reply := r.fanOut(ctx, targets, func(ctx context.Context, t target) string {
+		snapshot := record.Snapshots[t.name]
+		if th, ok := r.thermostats.byName[t.name]; ok {
+			th.Disable()
+			if snapshot.Band != nil {
+				if err := th.Enable(*snapshot.Band); err != nil {
+					r.logger.Printf("unable to restore the thermostat. device: %s cause: %v", t.name, err)
+				}
+			}
+		}
+		changes, err := t.hvac.Apply(ctx, snapshot.Settings)
+		r.record(e, c, t, audit.Changes(changes), err)
+		if err != nil {
+			r.logger.Printf("away restore failed. device: %s cause: %v", t.name, err)
+		}
+		return presenter.Changes(changes)
+	})
+	if err := r.thermostats.persist(); err != nil {
+		r.logger.Printf("unable to persist thermostats. cause: %v", err)
+	}
+	r.logger.Printf("away mode ended: %s", reason)
+	return fmt.Sprintf(":house: %s, restoring the settings from before leaving\n%s", reason, reply)
+}
+
+// refuse commands which change settings while away unless the user is an
+// admin, true when refused
+func (r *Receiver) awayRefused(ctx context.Context, c *Command, e *adapter.Event) bool {
+	record, ok := r.away.active()
+	if !ok || r.auth.role(e.User, e.Channel) >= RoleAdmin {
+		return false
+	}
+	r.logger.Printf("refused while away: %s user: %s channel: %s", c.Verb, e.User, e.Channel)
+	r.replyPrivately(e, fmt.Sprintf(":airplane: the house is in away mode until %s so only admins can change settings. `away cancel` to return early", presenter.Time(record.Until.In(r.location))))
+	return true
+}
+
+// the return given as `until <date> [time]` or `for <duration>`
+func parseReturn(c *Command, now time.Time) (time.Time, error) {
+	expr := strings.Join(c.Args[1:], " ")
+	if strings.ToLower(c.Arg(0)) == "for" {
+		d, err := parseDuration(expr)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("I don't understand `for %s`, try something like `for 3d`", expr)
+		}
+		return now.Add(d), nil
+	}
+	for _, f := range awayFormats {
+		if t, err := time.ParseInLocation(f, expr, now.Location()); err == nil {
+			if !t.After(now) {
+				return time.Time{}, fmt.Errorf("`%s` is in the past", expr)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("I don't understand `until %s`, try something like `until 2026-11-02` or `until 2026-11-02 18:00`", expr)
+}
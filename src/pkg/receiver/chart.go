@@ -0,0 +1,66 @@
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nullify005/chat-hvac/pkg/adapter"
+	"github.com/nullify005/chat-hvac/pkg/chart"
+	"github.com/nullify005/chat-hvac/pkg/history"
+	"github.com/nullify005/chat-hvac/pkg/presenter"
+)
+
+const (
+	chartUsage string = "[device|all] [today|yesterday|<duration>]"
+	// the period charted when none is given
+	defaultChartPeriod string = "12h"
+	// the number of characters in a sparkline
+	sparklineWidth int = 48
+)
+
+// chart the room temperature against the setpoint of the target device(s),
+// uploaded as an image where the adapter supports it otherwise as sparklines
+func chartHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	args, expr := c.Args, defaultChartPeriod
+	if len(args) > 0 && isPeriod(args[len(args)-1]) {
+		args, expr = args[:len(args)-1], args[len(args)-1]
+	}
+	uploader, canUpload := r.adapter.(adapter.Uploader)
+	reply := r.queryHistory(ctx, strings.Join(args, " "), expr, e, func(t target, label string, samples []history.Sample) string {
+		lo, hi := chart.Range(samples)
+		text := presenter.Sparklines(label,
+			chart.Sparkline(samples, history.Temperature, sparklineWidth, lo, hi),
+			chart.Sparkline(samples, history.Setpoint, sparklineWidth, lo, hi),
+			lo, hi)
+		if !canUpload {
+			return text
+		}
+		if err := r.uploadChart(uploader, t.name, label, samples, e); err != nil {
+			r.logger.Printf("unable to upload chart. cause: %v", err)
+			return text
+		}
+		return ""
+	})
+	if reply == "" {
+		return
+	}
+	r.reply(e, reply)
+}
+
+// render the samples of the device as a png & post it to the channel of the event
+func (r *Receiver) uploadChart(u adapter.Uploader, device, label string, samples []history.Sample, e *adapter.Event) error {
+	title := fmt.Sprintf("%s temperature °C %s", device, label)
+	content, err := chart.PNG(title, samples)
+	if err != nil {
+		return err
+	}
+	return u.Upload(adapter.File{
+		Name:      fmt.Sprintf("%s-%s.png", device, time.Now().Format("20060102-1504")),
+		Title:     title,
+		Content:   content,
+		Channel:   e.Channel,
+		Timestamp: e.Timestamp,
+	})
+}
@@ -0,0 +1,187 @@
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nullify005/chat-hvac/pkg/adapter"
+)
+
+const defaultConfirmTimeout time.Duration = 30 * time.Second
+
+// the commands which require confirmation unless configured otherwise
+var defaultConfirm = []string{"shutdown", "set power off"}
+
+// a command awaiting confirmation from the user who sent it
+type pending struct {
+	command *Command
+	event   adapter.Event
+	verb    ReceiverVerb
+	timer   *time.Timer
+}
+
+// tracks the commands awaiting confirmation, keyed by user, channel & thread
+type confirmations struct {
+	mu       sync.Mutex
+	patterns [][]string
+	timeout  time.Duration
+	pending  map[string]*pending
+}
+
+func newConfirmations() *confirmations {
+	c := &confirmations{
+		timeout: defaultConfirmTimeout,
+		pending: map[string]*pending{},
+	}
+	c.setPatterns(defaultConfirm)
+	return c
+}
+
+// replace the patterns which require confirmation. a pattern matches when it
+// prefixes the verb & arguments, eg. `set power off` or `shutdown`
+func (c *confirmations) setPatterns(patterns []string) {
+	c.patterns = [][]string{}
+	for _, p := range patterns {
+		if fields := strings.Fields(strings.ToLower(p)); len(fields) > 0 {
+			c.patterns = append(c.patterns, fields)
+		}
+	}
+}
+
+// whether the command matches any of the patterns
+func (c *confirmations) required(cmd *Command) bool {
+	for _, action := range actions(cmd) {
+		for _, p := range c.patterns {
+			if hasPrefix(action, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hold the command until it's confirmed or expired, replacing anything
+// already pending for the same key. expired is called if it isn't confirmed
+func (c *confirmations) hold(p *pending, expired func(*pending)) {
+	key := confirmKey(&p.event)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if existing, ok := c.pending[key]; ok {
+		existing.timer.Stop()
+	}
+	p.timer = time.AfterFunc(c.timeout, func() {
+		c.mu.Lock()
+		current, ok := c.pending[key]
+		if ok && current == p {
+			delete(c.pending, key)
+		}
+		c.mu.Unlock()
+		if ok && current == p {
+			expired(p)
+		}
+	})
+	c.pending[key] = p
+}
+
+// remove & return the command pending for the event's key
+func (c *confirmations) take(e *adapter.Event) (*pending, bool) {
+	key := confirmKey(e)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pending[key]
+	if !ok {
+		return nil, false
+	}
+	p.timer.Stop()
+	delete(c.pending, key)
+	return p, true
+}
+
+// confirmations are scoped to the user within a channel, or within a thread
+// when the command was sent in one
+func confirmKey(e *adapter.Event) string {
+	return strings.Join([]string{e.User, e.Channel, e.Thread}, "/")
+}
+
+// the lower cased verb & arguments of each action the command performs. set
+// is expanded into an action per key with the device removed so that patterns
+// match regardless of the target, on & off are treated as set power
+func actions(cmd *Command) [][]string {
+	args := lower(cmd.Args)
+	if cmd.Verb == "on" || cmd.Verb == "off" {
+		return [][]string{{"set", "power", cmd.Verb}}
+	}
+	if cmd.Verb != "set" {
+		return [][]string{append([]string{cmd.Verb}, args...)}
+	}
+	actions := [][]string{}
+	if len(cmd.Params) == 0 {
+		if len(args) == 3 {
+			args = args[1:]
+		}
+		return append(actions, append([]string{cmd.Verb}, args...))
+	}
+	keys := []string{}
+	for key := range cmd.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		actions = append(actions, []string{cmd.Verb, strings.ToLower(key), strings.ToLower(cmd.Params[key])})
+	}
+	return actions
+}
+
+func hasPrefix(action, pattern []string) bool {
+	if len(pattern) > len(action) {
+		return false
+	}
+	for i := range pattern {
+		if action[i] != pattern[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func lower(list []string) []string {
+	l := []string{}
+	for _, s := range list {
+		l = append(l, strings.ToLower(s))
+	}
+	return l
+}
+
+// whether the command must be confirmed before it runs, applying a preset is
+// confirmed when any of its settings would be
+func (r *Receiver) confirmationRequired(v ReceiverVerb, c *Command) bool {
+	if r.confirm.required(c) {
+		return true
+	}
+	if v.names[0] != "preset" || len(c.Args) == 0 {
+		return false
+	}
+	settings, ok := r.presets.get(strings.ToLower(c.Args[0]))
+	if !ok {
+		return false
+	}
+	params := map[string]string{}
+	for _, s := range settings {
+		params[s.Key] = s.Value
+	}
+	return r.confirm.required(&Command{Verb: "set", Params: params})
+}
+
+// ask the user to confirm the command before it runs
+func (r *Receiver) requestConfirmation(ctx context.Context, v ReceiverVerb, c *Command, e *adapter.Event) {
+	r.logger.Printf("awaiting confirmation: %s user: %s channel: %s", c.Raw, e.User, e.Channel)
+	r.confirm.hold(&pending{command: c, event: *e, verb: v}, func(p *pending) {
+		r.logger.Printf("confirmation expired: %s user: %s channel: %s", p.command.Raw, p.event.User, p.event.Channel)
+		r.replyPrivately(&p.event, fmt.Sprintf("`%s` wasn't confirmed in time, so I've cancelled it.", p.command.Raw))
+	})
+	r.replyPrivately(e, fmt.Sprintf(":warning: reply `yes` within %s to confirm `%s`, or `no` to cancel.", r.confirm.timeout, c.Raw))
+}
@@ -0,0 +1,106 @@
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nullify005/chat-hvac/pkg/hvac"
+	"github.com/nullify005/chat-hvac/pkg/presenter"
+)
+
+const (
+	// the target which fans a command out to every device
+	allDevices string = "all"
+	// the name given to a device registered via WithHvac
+	defaultDeviceName string = "default"
+)
+
+// a device which a command has been resolved to
+type target struct {
+	name string
+	hvac *hvac.Hvac
+}
+
+// the sorted names of all registered devices
+func (r *Receiver) deviceNames() []string {
+	names := []string{}
+	for name := range r.devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// whether name refers to a registered device or all of them
+func (r *Receiver) isTarget(name string) bool {
+	if name == allDevices {
+		return true
+	}
+	_, ok := r.devices[name]
+	return ok
+}
+
+// resolve the devices a command applies to. an empty name falls back to the
+// channel default & then the only registered device
+func (r *Receiver) targets(name, channel string) ([]target, error) {
+	if name == "" {
+		name = r.channelDevices[channel]
+	}
+	if name == "" && len(r.devices) == 1 {
+		name = r.deviceNames()[0]
+	}
+	switch {
+	case name == allDevices:
+		targets := []target{}
+		for _, n := range r.deviceNames() {
+			targets = append(targets, target{name: n, hvac: r.devices[n]})
+		}
+		return targets, nil
+	case name == "":
+		return nil, fmt.Errorf("which device? choose one of: %s", strings.Join(append(r.deviceNames(), allDevices), ", "))
+	}
+	h, ok := r.devices[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown device: `%s`, choose one of: %s", name, strings.Join(append(r.deviceNames(), allDevices), ", "))
+	}
+	return []target{{name: name, hvac: h}}, nil
+}
+
+// run fn concurrently against each target & aggregate the replies in target
+// order. replies are labelled with the device name when there's more than one
+// & empty replies, such as those posted some other way, are omitted
+func (r *Receiver) fanOut(ctx context.Context, targets []target, fn func(context.Context, target) string) string {
+	replies := make([]string, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t target) {
+			defer wg.Done()
+			replies[i] = fn(ctx, t)
+		}(i, t)
+	}
+	wg.Wait()
+	if len(targets) == 1 {
+		return replies[0]
+	}
+	labelled := []string{}
+	for i, t := range targets {
+		if replies[i] != "" {
+			labelled = append(labelled, presenter.Device(t.name, replies[i]))
+		}
+	}
+	return strings.Join(labelled, "\n\n")
+}
+
+// resolve the named device & run fn against each target, returning the
+// aggregated reply or why the device couldn't be resolved
+func (r *Receiver) forTargets(ctx context.Context, name, channel string, fn func(context.Context, target) string) string {
+	targets, err := r.targets(name, channel)
+	if err != nil {
+		return presenter.Error(err)
+	}
+	return r.fanOut(ctx, targets, fn)
+}
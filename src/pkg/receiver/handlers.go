@@ -1,131 +1,443 @@
 package receiver
 
 import (
-	"regexp"
+	"context"
+	"fmt"
+	"strings"
 
 	"github.com/nullify005/chat-hvac/pkg/adapter"
+	"github.com/nullify005/chat-hvac/pkg/audit"
+	"github.com/nullify005/chat-hvac/pkg/hvac"
+	"github.com/nullify005/chat-hvac/pkg/presenter"
 )
 
 const (
-	setSignature      string = "(.+) set (.+) (.+)"
-	statusSignature   string = "(.+) (status|state)"
-	pingSignature     string = "(.+) (ping|hi|hello)"
-	helpSignature     string = "(.+) help"
-	shutdownSignature string = "(.+) shutdown"
-	defaultSignature  string = "(.+) .*"
-
-	helpReply     string = "I'm expecting something like\n`@hvac (help|status|set|get|shutdown) key [value]`"
+	statusFull string = "full"
+
 	shutdownReply string = "Shutdown command received. Going to sleep now, bye ..."
 	defaultReply  string = "I'm not sure what you are after. :shrug:"
 )
 
-// returns the default list of signatures which are supported and their associated handlers
-func defaultSignatures() []ReceiverSignature {
-	return []ReceiverSignature{
+// returns the default list of verbs which are supported and their associated handlers
+func defaultVerbs() []ReceiverVerb {
+	return []ReceiverVerb{
+		{
+			names:   []string{"status", "state"},
+			usage:   "[device|all] [full]",
+			maxArgs: 2,
+			role:    RoleViewer,
+			handler: statusHandler,
+		},
+		{
+			names:   []string{"get"},
+			usage:   "[device|all] <key>",
+			minArgs: 1,
+			maxArgs: 2,
+			role:    RoleViewer,
+			handler: getHandler,
+		},
+		{
+			names:    []string{"set"},
+			usage:    "[device|all] <key> <value> | [device|all] <key>=<value> ... [in <duration>|at <time>]",
+			maxArgs:  3,
+			params:   true,
+			role:     RoleOperator,
+			handler:  setHandler,
+			settings: setArgs,
+			announce: true,
+		},
+		{
+			names:    []string{"on", "off"},
+			usage:    "[device|all] [in <duration>|at <time>]",
+			maxArgs:  1,
+			role:     RoleOperator,
+			handler:  powerHandler,
+			settings: powerArgs,
+			announce: true,
+		},
+		{
+			names:    []string{"panel"},
+			usage:    "[device|all]",
+			maxArgs:  1,
+			role:     RoleViewer,
+			handler:  panelHandler,
+			announce: true,
+		},
+		{
+			names:    []string{"preset", "presets"},
+			usage:    presetUsage,
+			maxArgs:  3,
+			role:     RoleViewer,
+			handler:  presetHandler,
+			argRole:  presetRole,
+			changes:  presetApplies,
+			announce: true,
+		},
+		{
+			names:   []string{"alerts", "alert"},
+			role:    RoleViewer,
+			handler: alertsHandler,
+		},
+		{
+			names:    []string{"ack", "acknowledge"},
+			usage:    "[id|all]",
+			maxArgs:  1,
+			role:     RoleOperator,
+			handler:  ackHandler,
+			announce: true,
+		},
+		{
+			names:    []string{"timer", "timers"},
+			usage:    timerUsage,
+			maxArgs:  2,
+			role:     RoleViewer,
+			handler:  timerHandler,
+			argRole:  timerRole,
+			announce: true,
+		},
+		{
+			names:    []string{"thermostat"},
+			usage:    thermostatUsage,
+			maxArgs:  2,
+			role:     RoleViewer,
+			handler:  thermostatHandler,
+			argRole:  thermostatRole,
+			changes:  thermostatChanges,
+			announce: true,
+		},
+		{
+			names:    []string{"away"},
+			usage:    awayUsage,
+			maxArgs:  3,
+			role:     RoleViewer,
+			handler:  awayHandler,
+			argRole:  awayRole,
+			announce: true,
+		},
 		{
-			signature: regexp.MustCompile(setSignature),
-			handler:   setHandler,
+			names:   []string{"audit"},
+			usage:   auditUsage,
+			maxArgs: 1,
+			role:    RoleViewer,
+			handler: auditHandler,
 		},
 		{
-			signature: regexp.MustCompile(statusSignature),
-			handler:   statusHandler,
+			names:   []string{"who"},
+			usage:   whoUsage,
+			minArgs: 2,
+			maxArgs: 3,
+			role:    RoleViewer,
+			handler: whoHandler,
 		},
 		{
-			signature: regexp.MustCompile(helpSignature),
-			handler:   helpHandler,
+			names:   []string{"history"},
+			usage:   historyUsage,
+			maxArgs: 2,
+			role:    RoleViewer,
+			handler: historyHandler,
 		},
 		{
-			signature: regexp.MustCompile(pingSignature),
-			handler:   pingHandler,
+			names:   []string{"chart", "graph"},
+			usage:   chartUsage,
+			maxArgs: 2,
+			role:    RoleViewer,
+			handler: chartHandler,
 		},
 		{
-			signature: regexp.MustCompile(shutdownSignature),
-			handler:   shutdownHandler,
+			names:   []string{"avg", "average", "min", "max"},
+			usage:   statUsage,
+			minArgs: 1,
+			maxArgs: 3,
+			role:    RoleViewer,
+			handler: statHandler,
 		},
 		{
-			signature: regexp.MustCompile(defaultSignature),
-			handler:   defaultHandler,
+			names:    []string{"schedule", "schedules"},
+			usage:    scheduleUsage,
+			maxArgs:  -1,
+			params:   true,
+			role:     RoleViewer,
+			handler:  scheduleHandler,
+			argRole:  scheduleRole,
+			announce: true,
+		},
+		{
+			names:   []string{"help"},
+			usage:   "[verb]",
+			maxArgs: 1,
+			role:    RoleViewer,
+			handler: helpHandler,
+		},
+		{
+			names:   []string{"ping", "hi", "hello", "wave"},
+			role:    RoleViewer,
+			handler: pingHandler,
+		},
+		{
+			names:   []string{"yes", "y", "confirm"},
+			role:    RoleViewer,
+			handler: confirmHandler,
+		},
+		{
+			names:   []string{"no", "n", "cancel"},
+			role:    RoleViewer,
+			handler: cancelHandler,
+		},
+		{
+			names:    []string{"shutdown"},
+			role:     RoleAdmin,
+			handler:  shutdownHandler,
+			announce: true,
 		},
 	}
 }
 
-// the default handler which catches any mention which didn't get processed by
-// another handler
-func defaultHandler(r *Receiver, s *regexp.Regexp, e *adapter.Event) {
-	m := adapter.Message{
-		Text:      defaultReply + "\n" + helpReply,
-		Channel:   e.Channel,
-		Threaded:  false,
-		Timestamp: e.Timestamp,
+// the usage of every registered verb
+func (r *Receiver) helpReply() string {
+	lines := []string{"I'm expecting something like"}
+	for _, v := range r.verbs {
+		lines = append(lines, v.help())
 	}
-	r.adapter.Say(m)
+	return strings.Join(lines, "\n")
 }
 
-// sends the help message
-func helpHandler(r *Receiver, s *regexp.Regexp, e *adapter.Event) {
-	m := adapter.Message{
-		Text:      helpReply,
-		Channel:   e.Channel,
-		Threaded:  false,
-		Timestamp: e.Timestamp,
+// the default handler which catches any message which didn't match a verb
+func defaultHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	r.replyPrivately(e, defaultReply+"\n"+r.helpReply())
+}
+
+// replies with why a message couldn't be parsed
+func parseErrorHandler(ctx context.Context, r *Receiver, err error, e *adapter.Event) {
+	r.replyPrivately(e, fmt.Sprintf(":x: I couldn't make sense of that, %v", err))
+}
+
+// politely refuses a verb which the user doesn't hold the role for
+func deniedHandler(ctx context.Context, r *Receiver, role Role, c *Command, e *adapter.Event) {
+	r.replyPrivately(e, fmt.Sprintf("Sorry <@%s>, you need the %s role to `%s`. :lock:", e.User, role, c.Verb))
+}
+
+// replies with the usage of a verb which was given the wrong arguments
+func usageHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	v, _ := r.verb(c.Verb)
+	r.replyPrivately(e, fmt.Sprintf(":x: usage: %s", v.help()))
+}
+
+// sends the help message, or the usage of a single verb
+func helpHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	reply := r.helpReply()
+	if verb := c.Arg(0); verb != "" {
+		if v, ok := r.verb(strings.ToLower(verb)); ok {
+			reply = v.help()
+		} else {
+			reply = fmt.Sprintf("I don't know how to `%s`\n%s", verb, reply)
+		}
 	}
-	r.adapter.Say(m)
+	r.reply(e, reply)
 }
 
 // respond to hello are you there requests
-func pingHandler(r *Receiver, s *regexp.Regexp, e *adapter.Event) {
+func pingHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
 	reply := "Err, not sure how I ended up here in the ping handler to be honest ... :confused:"
-	match := s.FindSubmatch([]byte(e.Message))
-	switch {
-	case string(match[2]) == "ping":
+	switch c.Verb {
+	case "ping":
 		reply = "pong"
-	case string(match[2]) == "hi":
+	case "hi":
 		reply = ":wave:"
-	case string(match[2]) == "hello":
+	case "hello":
 		reply = "Yes, I'm listening ..."
-	case string(match[2]) == "wave":
+	case "wave":
 		reply = ":wave:"
 	}
-	m := adapter.Message{
-		Text:      reply,
-		Channel:   e.Channel,
-		Threaded:  false,
-		Timestamp: e.Timestamp,
+	r.reply(e, reply)
+}
+
+// set hvac key(s) to a value on the target device(s)
+func setHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	device, params, ok := setArgs(c)
+	if !ok {
+		usageHandler(ctx, r, c, e)
+		return
 	}
-	r.adapter.Say(m)
+	reply := r.forTargets(ctx, device, e.Channel, func(ctx context.Context, t target) string {
+		return r.apply(ctx, e, c, t, params)
+	})
+	r.reply(e, reply)
 }
 
-// get the hvac status
-func setHandler(r *Receiver, s *regexp.Regexp, e *adapter.Event) {
-	match := s.FindSubmatch([]byte(e.Message))
-	m := adapter.Message{
-		Text:      r.hvac.Set(string(match[2]), string(match[3])),
-		Channel:   e.Channel,
-		Threaded:  false,
-		Timestamp: e.Timestamp,
+// turn the target device(s) on or off, shorthand for set power on|off
+func powerHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	device, params, _ := powerArgs(c)
+	reply := r.forTargets(ctx, device, e.Channel, func(ctx context.Context, t target) string {
+		return r.apply(ctx, e, c, t, params)
+	})
+	r.reply(e, reply)
+}
+
+// set each key to its value in order on the device, reporting each outcome.
+// the command is audited on behalf of the user behind the event
+func (r *Receiver) apply(ctx context.Context, e *adapter.Event, c *Command, t target, params [][2]string) string {
+	replies := []string{}
+	changes := []audit.Change{}
+	for _, p := range params {
+		res, err := t.hvac.Set(ctx, p[0], p[1])
+		changes = append(changes, audit.SetChange(p[0], p[1], res, err))
+		if err != nil {
+			r.logger.Printf("set failed. device: %s cause: %v", t.name, err)
+			replies = append(replies, presenter.Error(err))
+			continue
+		}
+		replies = append(replies, presenter.Set(res))
 	}
-	r.adapter.Say(m)
+	r.record(e, c, t, changes, nil)
+	return strings.Join(replies, "\n")
 }
 
-// receive and process the shutdown command
-func shutdownHandler(r *Receiver, s *regexp.Regexp, e *adapter.Event) {
-	m := adapter.Message{
-		Text:      shutdownReply,
-		Channel:   e.Channel,
-		Threaded:  false,
-		Timestamp: e.Timestamp,
+// the device & power setting of an on|off command
+func powerArgs(c *Command) (string, [][2]string, bool) {
+	return c.Arg(0), [][2]string{{"power", c.Verb}}, true
+}
+
+// the device & key value pairs of a set command, either positional as
+// [device] key value or as [device] key=value ...
+func setArgs(c *Command) (string, [][2]string, bool) {
+	params := [][2]string{}
+	switch {
+	case len(c.Params) > 0 && len(c.Args) <= 1:
+		// in the order hvac.Apply uses, so that eg. the setpoint is validated
+		// against the new mode
+		settings := []hvac.Setting{}
+		for key, value := range c.Params {
+			settings = append(settings, hvac.Setting{Key: key, Value: value})
+		}
+		for _, s := range hvac.Ordered(settings) {
+			params = append(params, [2]string{s.Key, s.Value})
+		}
+		return c.Arg(0), params, true
+	case len(c.Params) == 0 && len(c.Args) == 3:
+		return c.Args[0], append(params, [2]string{c.Args[1], c.Args[2]}), true
+	case len(c.Params) == 0 && len(c.Args) == 2:
+		return "", append(params, [2]string{c.Args[0], c.Args[1]}), true
 	}
-	r.adapter.Say(m)
+	return "", nil, false
+}
+
+// get a single key from the hvac status of the target device(s)
+func getHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	device, key := "", c.Arg(0)
+	if len(c.Args) == 2 {
+		device, key = c.Args[0], c.Args[1]
+	}
+	reply := r.forTargets(ctx, device, e.Channel, func(ctx context.Context, t target) string {
+		key, value, err := t.hvac.Get(ctx, key)
+		if err != nil {
+			r.logger.Printf("get failed. device: %s cause: %v", t.name, err)
+			return presenter.Error(err)
+		}
+		return presenter.Field(key, value)
+	})
+	r.reply(e, reply)
+}
+
+// receive and process the shutdown command
+func shutdownHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	r.record(e, c, target{}, nil, nil)
+	r.reply(e, shutdownReply)
 	r.Shutdown()
 }
 
-// get the hvac status
-func statusHandler(r *Receiver, s *regexp.Regexp, e *adapter.Event) {
+// get the hvac status summary of the target device(s), or every field with full
+func statusHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	args := c.Args
+	full := false
+	if len(args) > 0 && args[len(args)-1] == statusFull {
+		full = true
+		args = args[:len(args)-1]
+	}
+	reply := r.forTargets(ctx, strings.Join(args, " "), e.Channel, func(ctx context.Context, t target) string {
+		status, err := t.hvac.Status(ctx)
+		if err != nil {
+			r.logger.Printf("status failed. device: %s cause: %v", t.name, err)
+			return presenter.Error(err)
+		}
+		if full {
+			return presenter.StatusFull(status)
+		}
+		return presenter.Status(status)
+	})
+	r.reply(e, reply)
+}
+
+// post an interactive control panel for each of the target device(s)
+func panelHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	targets, err := r.targets(c.Arg(0), e.Channel)
+	if err != nil {
+		r.reply(e, presenter.Error(err))
+		return
+	}
+	for _, t := range targets {
+		status, err := t.hvac.Status(ctx)
+		if err != nil {
+			r.logger.Printf("status failed. device: %s cause: %v", t.name, err)
+			r.reply(e, presenter.Device(t.name, presenter.Error(err)))
+			continue
+		}
+		p := presenter.Panel(t.name, status, "")
+		r.respond(e, adapter.Message{Text: presenter.PanelText(p), Panel: p})
+	}
+}
+
+// apply an action from a control panel & update the panel in place with the
+// new device state
+func actionHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	targets, err := r.targets(e.Action.Device, e.Channel)
+	if err != nil {
+		r.logger.Printf("ignoring action: %+v cause: %v", e.Action, err)
+		return
+	}
+	t := targets[0]
+	var notice string
+	res, err := t.hvac.Set(ctx, e.Action.Key, e.Action.Value)
+	r.record(e, c, t, []audit.Change{audit.SetChange(e.Action.Key, e.Action.Value, res, err)}, nil)
+	if err != nil {
+		r.logger.Printf("set failed. device: %s cause: %v", t.name, err)
+		notice = presenter.Error(err)
+	} else {
+		notice = fmt.Sprintf("<@%s> %s", e.User, presenter.Set(res))
+	}
+	status, err := t.hvac.Status(ctx)
+	if err != nil {
+		r.logger.Printf("status failed. device: %s cause: %v", t.name, err)
+		r.reply(e, presenter.Device(t.name, presenter.Error(err)))
+		return
+	}
+	p := presenter.Panel(t.name, status, notice)
 	m := adapter.Message{
-		Text:      r.hvac.Status(),
+		Text:      presenter.PanelText(p),
 		Channel:   e.Channel,
-		Threaded:  false,
 		Timestamp: e.Timestamp,
+		Panel:     p,
+		Update:    true,
 	}
 	r.adapter.Say(m)
 }
+
+// run the command awaiting confirmation from the user
+func confirmHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	p, ok := r.confirm.take(e)
+	if !ok {
+		r.reply(e, "There's nothing waiting for you to confirm. :thinking_face:")
+		return
+	}
+	r.logger.Printf("confirmed: %s user: %s channel: %s", p.command.Raw, e.User, e.Channel)
+	r.run(ctx, p.verb, p.command, &p.event)
+}
+
+// cancel the command awaiting confirmation from the user
+func cancelHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	reply := "There's nothing waiting for you to confirm. :thinking_face:"
+	if p, ok := r.confirm.take(e); ok {
+		r.logger.Printf("cancelled: %s user: %s channel: %s", p.command.Raw, e.User, e.Channel)
+		reply = fmt.Sprintf("OK, I've cancelled `%s`.", p.command.Raw)
+	}
+	r.reply(e, reply)
+}
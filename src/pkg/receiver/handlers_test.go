@@ -0,0 +1,35 @@
+package receiver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSetArgs(t *testing.T) {
+	tests := []struct {
+		message string
+		device  string
+		params  [][2]string
+		ok      bool
+	}{
+		{"set power on", "", [][2]string{{"power", "on"}}, true},
+		{"set lounge power on", "lounge", [][2]string{{"power", "on"}}, true},
+		// the mode changes before the setpoint is validated against it
+		{"set setpoint=18 mode=heat", "", [][2]string{{"mode", "heat"}, {"setpoint", "18"}}, true},
+		{"set lounge fan_speed=2 setpoint=24 power=on mode=cool", "lounge", [][2]string{{"power", "on"}, {"mode", "cool"}, {"setpoint", "24"}, {"fan_speed", "2"}}, true},
+		{"set vvane=swing Setpoint=20 MODE=cool", "", [][2]string{{"MODE", "cool"}, {"Setpoint", "20"}, {"vvane", "swing"}}, true},
+		{"set power", "", nil, false},
+		{"set lounge power on now", "", nil, false},
+		{"set lounge bedroom mode=cool", "", nil, false},
+	}
+	for _, tt := range tests {
+		c, err := Parse(tt.message)
+		if err != nil {
+			t.Fatalf("Parse(%q) = %v", tt.message, err)
+		}
+		device, params, ok := setArgs(c)
+		if device != tt.device || !reflect.DeepEqual(params, tt.params) || ok != tt.ok {
+			t.Errorf("setArgs(%q) = %q %v %t, want %q %v %t", tt.message, device, params, ok, tt.device, tt.params, tt.ok)
+		}
+	}
+}
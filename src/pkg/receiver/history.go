@@ -0,0 +1,132 @@
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nullify005/chat-hvac/pkg/adapter"
+	"github.com/nullify005/chat-hvac/pkg/history"
+	"github.com/nullify005/chat-hvac/pkg/presenter"
+)
+
+const (
+	historyUsage string = "[device|all] [today|yesterday|<duration>]"
+	statUsage    string = "[device|all] temp|setpoint [today|yesterday|<duration>]"
+	// the period queried when none is given
+	defaultPeriod string = "24h"
+)
+
+// records the polled status of each device so that it can be queried,
+// history requires polling to be enabled
+func WithHistory(h *history.Store) ReceiverOption {
+	return func(r *Receiver) {
+		r.history = h
+	}
+}
+
+// summarise the temperature & setpoint of the target device(s) over a period
+func historyHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	args, expr := c.Args, defaultPeriod
+	if len(args) > 0 && isPeriod(args[len(args)-1]) {
+		args, expr = args[:len(args)-1], args[len(args)-1]
+	}
+	reply := r.queryHistory(ctx, strings.Join(args, " "), expr, e, func(t target, label string, samples []history.Sample) string {
+		temperature, _ := history.Summarize(samples, history.Temperature)
+		setpoint, _ := history.Summarize(samples, history.Setpoint)
+		return presenter.History(label, temperature, setpoint)
+	})
+	r.reply(e, reply)
+}
+
+// the average, minimum or maximum of the temperature or setpoint of the
+// target device(s) over a period, eg. avg temp yesterday. the device may be
+// given either side of the field
+func statHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	var (
+		field history.Field
+		found bool
+		args  []string
+	)
+	for _, arg := range c.Args {
+		if f, ok := statField(arg); ok && !found {
+			field, found = f, true
+			continue
+		}
+		args = append(args, arg)
+	}
+	if !found {
+		usageHandler(ctx, r, c, e)
+		return
+	}
+	expr := defaultPeriod
+	if len(args) > 0 && isPeriod(args[len(args)-1]) {
+		args, expr = args[:len(args)-1], args[len(args)-1]
+	}
+	stat := c.Verb
+	reply := r.queryHistory(ctx, strings.Join(args, " "), expr, e, func(t target, label string, samples []history.Sample) string {
+		s, _ := history.Summarize(samples, field)
+		return presenter.Statistic(stat, label, s)
+	})
+	r.reply(e, reply)
+}
+
+// query the samples of each target device over the period & format them
+// with fn, devices without any samples are reported as such
+func (r *Receiver) queryHistory(ctx context.Context, device, expr string, e *adapter.Event, fn func(t target, label string, samples []history.Sample) string) string {
+	if r.history == nil || len(r.pollers) == 0 {
+		return "History isn't enabled, it needs polling. :chart_with_upwards_trend:"
+	}
+	from, to, label, err := period(expr, time.Now().In(r.location))
+	if err != nil {
+		return presenter.Error(err)
+	}
+	return r.forTargets(ctx, device, e.Channel, func(ctx context.Context, t target) string {
+		samples, err := r.history.Query(t.name, from, to)
+		if err != nil {
+			r.logger.Printf("history query failed. device: %s cause: %v", t.name, err)
+			return presenter.Error(err)
+		}
+		if len(samples) == 0 {
+			return fmt.Sprintf("There's no history for %s. :hourglass_flowing_sand:", label)
+		}
+		for i := range samples {
+			samples[i].Time = samples[i].Time.In(r.location)
+		}
+		return fn(t, label, samples)
+	})
+}
+
+// the field a stat is taken of
+func statField(arg string) (history.Field, bool) {
+	switch strings.ToLower(arg) {
+	case "temp", "temperature":
+		return history.Temperature, true
+	case "setpoint", "target":
+		return history.Setpoint, true
+	}
+	return "", false
+}
+
+func isPeriod(expr string) bool {
+	_, _, _, err := period(expr, time.Now())
+	return err == nil
+}
+
+// the range of a period, either today or yesterday in the location of now or
+// a duration back from now
+func period(expr string, now time.Time) (time.Time, time.Time, string, error) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch strings.ToLower(expr) {
+	case "today":
+		return midnight, now, "today", nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), midnight, "yesterday", nil
+	}
+	d, err := parseDuration(expr)
+	if err != nil {
+		return time.Time{}, time.Time{}, "", fmt.Errorf("I don't understand `%s`, try today, yesterday or a duration like 24h or 7d", expr)
+	}
+	return now.Add(-d), now, fmt.Sprintf("in the last %s", presenter.Duration(d)), nil
+}
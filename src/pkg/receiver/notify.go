@@ -0,0 +1,81 @@
+package receiver
+
+import (
+	"context"
+	"time"
+
+	"github.com/nullify005/chat-hvac/pkg/adapter"
+	"github.com/nullify005/chat-hvac/pkg/hvac"
+	"github.com/nullify005/chat-hvac/pkg/presenter"
+)
+
+// polls the status of every device on the interval & posts changes made
+// outside of chat, eg. via the remote, to the configured channel
+func WithPolling(interval time.Duration) ReceiverOption {
+	return func(r *Receiver) {
+		r.pollInterval = interval
+	}
+}
+
+// a poller & thermostat for every device when polling is enabled
+func (r *Receiver) newPollers() {
+	if r.pollInterval <= 0 {
+		return
+	}
+	for name, h := range r.devices {
+		r.pollers[name] = hvac.NewPoller(h, hvac.WithInterval(r.pollInterval))
+		r.thermostats.byName[name] = hvac.NewThermostat(h, r.thermostats.opts...)
+	}
+}
+
+// subscribe to & start each poller
+func (r *Receiver) startPollers(ctx context.Context) {
+	if r.alerter != nil {
+		r.alerter.Start(r.notifyAlert)
+	}
+	if r.history != nil && len(r.pollers) > 0 {
+		go r.history.Run(ctx)
+	}
+	for name, p := range r.pollers {
+		p.Subscribe(r.notifyChange(name))
+		if r.alerter != nil {
+			p.Subscribe(r.alerter.Subscriber(name))
+		}
+		if r.history != nil {
+			p.Subscribe(r.history.Subscriber(name))
+		}
+		th := r.thermostats.byName[name]
+		th.Subscribe(r.recordDecision(name))
+		p.Subscribe(th.Subscriber(ctx))
+		go p.Run(ctx)
+	}
+}
+
+// the subscriber which posts changes to the device named name, changes made
+// by us are skipped as they've already been reported where they were made
+func (r *Receiver) notifyChange(name string) hvac.Subscriber {
+	return func(e hvac.Event) {
+		switch e.Type {
+		case hvac.StatusPolled, hvac.PollFailed:
+			return
+		case hvac.ErrorRaised, hvac.ErrorCleared:
+			// reported by the alerter instead when it's enabled
+			if r.alerter != nil {
+				return
+			}
+		}
+		r.logger.Printf("device: %s changed: %s from: %s to: %s external: %t", name, e.Type, e.From, e.To, e.External)
+		if !e.External {
+			return
+		}
+		r.recordExternal(name, e)
+		if r.channel == "" {
+			return
+		}
+		text := presenter.Notification(e)
+		if len(r.devices) > 1 {
+			text = presenter.Device(name, text)
+		}
+		r.adapter.Say(adapter.Message{Text: text, Channel: r.channel})
+	}
+}
@@ -0,0 +1,154 @@
+package receiver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// the bare name of the bot, stripped when it leads a message eg. on the console
+const botName string = "hvac"
+
+var (
+	ErrEmptyCommand      = errors.New("empty command")
+	ErrUnterminatedQuote = errors.New("unterminated quote")
+)
+
+// opening quotes & their matching closing quote, slack will often replace
+// straight quotes with smart quotes
+var quotes = map[rune]rune{
+	'"':  '"',
+	'\'': '\'',
+	'“':  '”',
+	'‘':  '’',
+}
+
+// a chat message parsed into a verb & its arguments
+type Command struct {
+	Verb   string            // lower cased
+	Args   []string          // positional arguments with any quotes removed
+	Params map[string]string // key=value arguments
+	Raw    string            // the message without the leading mention
+	When   time.Time         // when a deferred command should run, zero for immediately
+}
+
+// a single token from the message, literal tokens began with a quote & are
+// never treated as a mention or key=value pair
+type token struct {
+	text    string
+	literal bool
+}
+
+// parse a message into a Command, stripping any leading mention of the bot
+func Parse(message string) (*Command, error) {
+	tokens, err := tokenize(message)
+	if err != nil {
+		return nil, err
+	}
+	raw := strings.TrimSpace(message)
+	if len(tokens) > 0 && !tokens[0].literal && isMention(tokens[0].text) {
+		raw = strings.TrimSpace(strings.TrimPrefix(raw, tokens[0].text))
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 0 {
+		return nil, ErrEmptyCommand
+	}
+	c := &Command{
+		Verb:   strings.ToLower(tokens[0].text),
+		Args:   []string{},
+		Params: map[string]string{},
+		Raw:    raw,
+	}
+	for _, t := range tokens[1:] {
+		if key, value, ok := param(t); ok {
+			c.Params[key] = value
+			continue
+		}
+		c.Args = append(c.Args, t.text)
+	}
+	return c, nil
+}
+
+// the argument at i or an empty string
+func (c *Command) Arg(i int) string {
+	if i < len(c.Args) {
+		return c.Args[i]
+	}
+	return ""
+}
+
+// split a message on whitespace, keeping quoted sections together
+func tokenize(message string) ([]token, error) {
+	tokens := []token{}
+	var (
+		current  strings.Builder
+		inToken  bool
+		literal  bool
+		closing  rune
+		inQuotes bool
+		prev     rune
+	)
+	for _, c := range message {
+		// quotes only open at the start of a token or a value so that
+		// apostrophes within words are left alone
+		end, isQuote := quotes[c]
+		isQuote = isQuote && (!inToken || prev == '=')
+		prev = c
+		switch {
+		case inQuotes && c == closing:
+			inQuotes = false
+		case inQuotes:
+			current.WriteRune(c)
+		case unicode.IsSpace(c):
+			if inToken {
+				tokens = append(tokens, token{text: current.String(), literal: literal})
+				current.Reset()
+				inToken, literal = false, false
+			}
+		default:
+			if isQuote {
+				inQuotes, closing = true, end
+				literal = literal || !inToken
+			} else {
+				current.WriteRune(c)
+			}
+			inToken = true
+		}
+	}
+	if inQuotes {
+		return nil, ErrUnterminatedQuote
+	}
+	if inToken {
+		tokens = append(tokens, token{text: current.String(), literal: literal})
+	}
+	return tokens, nil
+}
+
+// whether the token addresses the bot rather than being part of the command
+func isMention(t string) bool {
+	return strings.HasPrefix(t, "<@") ||
+		strings.HasPrefix(t, "@") ||
+		strings.HasPrefix(t, "/") ||
+		strings.EqualFold(t, botName)
+}
+
+// split a key=value token, the value may be quoted but the key may not
+func param(t token) (string, string, bool) {
+	i := strings.Index(t.text, "=")
+	if t.literal || i <= 0 {
+		return "", "", false
+	}
+	return t.text[:i], t.text[i+1:], true
+}
+
+// a command which doesn't satisfy its verb's usage
+type UsageError struct {
+	Verb   string
+	Reason string
+}
+
+func (e *UsageError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Verb, e.Reason)
+}
@@ -0,0 +1,152 @@
+package receiver
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    *Command
+		err     error
+	}{
+		{
+			name:    "mention",
+			message: "<@U123> Status",
+			want:    &Command{Verb: "status", Args: []string{}, Params: map[string]string{}, Raw: "Status"},
+		},
+		{
+			name:    "bare bot name",
+			message: "hvac set power on",
+			want:    &Command{Verb: "set", Args: []string{"power", "on"}, Params: map[string]string{}, Raw: "set power on"},
+		},
+		{
+			name:    "slash command",
+			message: "/hvac off lounge",
+			want:    &Command{Verb: "off", Args: []string{"lounge"}, Params: map[string]string{}, Raw: "off lounge"},
+		},
+		{
+			name:    "surrounding whitespace",
+			message: "  @hvac   set  power   on  ",
+			want:    &Command{Verb: "set", Args: []string{"power", "on"}, Params: map[string]string{}, Raw: "set  power   on"},
+		},
+		{
+			name:    "params",
+			message: "set lounge setpoint=21 mode=cool",
+			want:    &Command{Verb: "set", Args: []string{"lounge"}, Params: map[string]string{"setpoint": "21", "mode": "cool"}, Raw: "set lounge setpoint=21 mode=cool"},
+		},
+		{
+			name:    "value containing an equals",
+			message: "set a=b=c",
+			want:    &Command{Verb: "set", Args: []string{}, Params: map[string]string{"a": "b=c"}, Raw: "set a=b=c"},
+		},
+		{
+			name:    "empty value",
+			message: "set mode=",
+			want:    &Command{Verb: "set", Args: []string{}, Params: map[string]string{"mode": ""}, Raw: "set mode="},
+		},
+		{
+			name:    "missing key",
+			message: "set =cool",
+			want:    &Command{Verb: "set", Args: []string{"=cool"}, Params: map[string]string{}, Raw: "set =cool"},
+		},
+		{
+			name:    "double quoted argument",
+			message: `preset save "movie night"`,
+			want:    &Command{Verb: "preset", Args: []string{"save", "movie night"}, Params: map[string]string{}, Raw: `preset save "movie night"`},
+		},
+		{
+			name:    "single quoted argument",
+			message: `preset save 'movie night'`,
+			want:    &Command{Verb: "preset", Args: []string{"save", "movie night"}, Params: map[string]string{}, Raw: `preset save 'movie night'`},
+		},
+		{
+			name:    "smart quoted argument",
+			message: "preset save “movie night”",
+			want:    &Command{Verb: "preset", Args: []string{"save", "movie night"}, Params: map[string]string{}, Raw: "preset save “movie night”"},
+		},
+		{
+			name:    "quoted value",
+			message: `set name="living room"`,
+			want:    &Command{Verb: "set", Args: []string{}, Params: map[string]string{"name": "living room"}, Raw: `set name="living room"`},
+		},
+		{
+			name:    "quoted key value is literal",
+			message: `set "mode=cool"`,
+			want:    &Command{Verb: "set", Args: []string{"mode=cool"}, Params: map[string]string{}, Raw: `set "mode=cool"`},
+		},
+		{
+			name:    "quoted mention is literal",
+			message: `"@hvac" status`,
+			want:    &Command{Verb: "@hvac", Args: []string{"status"}, Params: map[string]string{}, Raw: `"@hvac" status`},
+		},
+		{
+			name:    "apostrophe within a word",
+			message: "preset save don't",
+			want:    &Command{Verb: "preset", Args: []string{"save", "don't"}, Params: map[string]string{}, Raw: "preset save don't"},
+		},
+		{
+			name:    "empty quotes",
+			message: `preset save ""`,
+			want:    &Command{Verb: "preset", Args: []string{"save", ""}, Params: map[string]string{}, Raw: `preset save ""`},
+		},
+		{
+			name:    "empty",
+			message: "   ",
+			err:     ErrEmptyCommand,
+		},
+		{
+			name:    "only a mention",
+			message: "<@U123>",
+			err:     ErrEmptyCommand,
+		},
+		{
+			name:    "unterminated quote",
+			message: `preset save "movie night`,
+			err:     ErrUnterminatedQuote,
+		},
+		{
+			name:    "unterminated smart quote",
+			message: "preset save “movie night\"",
+			err:     ErrUnterminatedQuote,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.message)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Parse(%q) error = %v, want %v", tt.message, err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.message, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		message string
+		want    []token
+	}{
+		{"", []token{}},
+		{"a  b\tc\n", []token{{text: "a"}, {text: "b"}, {text: "c"}}},
+		{`a "b c" d`, []token{{text: "a"}, {text: "b c", literal: true}, {text: "d"}}},
+		{`k="v w"`, []token{{text: "k=v w"}}},
+		{`ab"c`, []token{{text: `ab"c`}}},
+		{`"a"b`, []token{{text: "ab", literal: true}}},
+		{"‘a b’", []token{{text: "a b", literal: true}}},
+	}
+	for _, tt := range tests {
+		got, err := tokenize(tt.message)
+		if err != nil {
+			t.Fatalf("tokenize(%q) error = %v", tt.message, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenize(%q) = %+v, want %+v", tt.message, got, tt.want)
+		}
+	}
+}
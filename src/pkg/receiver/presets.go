@@ -0,0 +1,245 @@
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nullify005/chat-hvac/pkg/adapter"
+	"github.com/nullify005/chat-hvac/pkg/audit"
+	"github.com/nullify005/chat-hvac/pkg/hvac"
+	"github.com/nullify005/chat-hvac/pkg/presenter"
+	"github.com/nullify005/chat-hvac/pkg/store"
+)
+
+const presetUsage string = "<name> [device|all] | save <name> [device] | list | delete <name>"
+
+// named settings applied together, those defined in config can't be replaced
+// from chat while saved ones are persisted to path
+type presets struct {
+	mu      sync.Mutex
+	path    string
+	defined map[string][]hvac.Setting
+	saved   map[string][]hvac.Setting
+}
+
+func newPresets() *presets {
+	return &presets{
+		defined: map[string][]hvac.Setting{},
+		saved:   map[string][]hvac.Setting{},
+	}
+}
+
+// a preset defined in config, which chat can't replace or delete
+func WithPreset(name string, settings map[string]string) ReceiverOption {
+	return func(r *Receiver) {
+		list := []hvac.Setting{}
+		for key, value := range settings {
+			list = append(list, hvac.Setting{Key: key, Value: value})
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+		r.presets.defined[strings.ToLower(name)] = list
+	}
+}
+
+// the json file presets saved from chat are persisted to, when empty they're
+// lost on restart
+func WithPresetPath(p string) ReceiverOption {
+	return func(r *Receiver) {
+		r.presets.path = p
+	}
+}
+
+// load the presets saved from chat
+func (p *presets) load() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.path == "" {
+		return nil
+	}
+	return store.Load(p.path, &p.saved)
+}
+
+// the preset named name
+func (p *presets) get(name string) ([]hvac.Setting, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok := p.defined[name]; ok {
+		return s, true
+	}
+	s, ok := p.saved[name]
+	return s, ok
+}
+
+// save & persist a preset, replacing any previously saved under name
+func (p *presets) save(name string, settings []hvac.Setting) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.defined[name]; ok {
+		return fmt.Errorf("the preset `%s` is defined in config so it can't be replaced", name)
+	}
+	previous, existed := p.saved[name]
+	p.saved[name] = settings
+	if err := p.persist(); err != nil {
+		if existed {
+			p.saved[name] = previous
+		} else {
+			delete(p.saved, name)
+		}
+		return err
+	}
+	return nil
+}
+
+// delete & persist the removal of a saved preset
+func (p *presets) delete(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.defined[name]; ok {
+		return fmt.Errorf("the preset `%s` is defined in config so it can't be deleted", name)
+	}
+	settings, ok := p.saved[name]
+	if !ok {
+		return fmt.Errorf("there's no preset named `%s`", name)
+	}
+	delete(p.saved, name)
+	if err := p.persist(); err != nil {
+		p.saved[name] = settings
+		return err
+	}
+	return nil
+}
+
+// every preset formatted & ordered by name
+func (p *presets) list() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	names := []string{}
+	for name := range p.defined {
+		names = append(names, name)
+	}
+	for name := range p.saved {
+		if _, ok := p.defined[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "There are no presets, save one with `@hvac preset save <name>`. :art:"
+	}
+	sort.Strings(names)
+	lines := []string{}
+	for _, name := range names {
+		if s, ok := p.defined[name]; ok {
+			lines = append(lines, presenter.Preset(name, s))
+			continue
+		}
+		lines = append(lines, presenter.Preset(name, p.saved[name]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// persist the saved presets, the caller must hold the lock
+func (p *presets) persist() error {
+	if p.path == "" {
+		return nil
+	}
+	return store.Save(p.path, p.saved)
+}
+
+// listing the presets is open to viewers, applying, saving & deleting them
+// require an operator
+func presetRole(c *Command) Role {
+	switch strings.ToLower(c.Arg(0)) {
+	case "list", "ls", "":
+		return RoleViewer
+	}
+	return RoleOperator
+}
+
+// whether the command applies a preset rather than managing them
+func presetApplies(c *Command) bool {
+	switch strings.ToLower(c.Arg(0)) {
+	case "list", "ls", "", "save", "delete", "remove", "rm":
+		return false
+	}
+	return true
+}
+
+// apply a preset to the target device(s), or manage the presets
+func presetHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	var reply string
+	switch strings.ToLower(c.Arg(0)) {
+	case "list", "ls", "":
+		reply = r.presets.list()
+	case "save":
+		if len(c.Args) < 2 || len(c.Args) > 3 {
+			usageHandler(ctx, r, c, e)
+			return
+		}
+		reply = r.savePreset(ctx, strings.ToLower(c.Args[1]), c.Arg(2), e)
+	case "delete", "remove", "rm":
+		if len(c.Args) != 2 {
+			usageHandler(ctx, r, c, e)
+			return
+		}
+		name := strings.ToLower(c.Args[1])
+		if err := r.presets.delete(name); err != nil {
+			reply = presenter.Error(err)
+			break
+		}
+		r.logger.Printf("deleted preset: %s user: %s", name, e.User)
+		reply = fmt.Sprintf(":wastebasket: deleted the preset `%s`", name)
+	default:
+		if len(c.Args) > 2 {
+			usageHandler(ctx, r, c, e)
+			return
+		}
+		reply = r.applyPreset(ctx, strings.ToLower(c.Args[0]), c.Arg(1), c, e)
+	}
+	r.reply(e, reply)
+}
+
+// snapshot the current state of a single device as a preset
+func (r *Receiver) savePreset(ctx context.Context, name, device string, e *adapter.Event) string {
+	targets, err := r.targets(device, e.Channel)
+	if err != nil {
+		return presenter.Error(err)
+	}
+	if len(targets) != 1 {
+		return ":x: a preset can only be saved from a single device"
+	}
+	status, err := targets[0].hvac.Status(ctx)
+	if err != nil {
+		r.logger.Printf("status failed. device: %s cause: %v", targets[0].name, err)
+		return presenter.Error(err)
+	}
+	settings := status.Snapshot()
+	if err := r.presets.save(name, settings); err != nil {
+		r.logger.Printf("unable to save preset: %s cause: %v", name, err)
+		return presenter.Error(err)
+	}
+	r.logger.Printf("saved preset: %s settings: %+v user: %s", name, settings, e.User)
+	return fmt.Sprintf(":floppy_disk: saved %s", presenter.Preset(name, settings))
+}
+
+// apply each setting of the preset to the target device(s), rolling back a
+// device's changes when one of them fails
+func (r *Receiver) applyPreset(ctx context.Context, name, device string, c *Command, e *adapter.Event) string {
+	settings, ok := r.presets.get(name)
+	if !ok {
+		return fmt.Sprintf(":x: there's no preset named `%s`, try `@%s preset list`", name, botName)
+	}
+	return r.forTargets(ctx, device, e.Channel, func(ctx context.Context, t target) string {
+		changes, err := t.hvac.Apply(ctx, settings)
+		r.record(e, c, t, audit.Changes(changes), err)
+		if err != nil {
+			r.logger.Printf("preset: %s failed. device: %s cause: %v", name, t.name, err)
+			if changes == nil {
+				return presenter.Error(err)
+			}
+		}
+		return presenter.Changes(changes)
+	})
+}
@@ -1,30 +1,102 @@
 package receiver
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
-	"regexp"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/nullify005/chat-hvac/pkg/adapter"
+	"github.com/nullify005/chat-hvac/pkg/alert"
+	"github.com/nullify005/chat-hvac/pkg/audit"
+	"github.com/nullify005/chat-hvac/pkg/history"
 	"github.com/nullify005/chat-hvac/pkg/hvac"
+	"github.com/nullify005/chat-hvac/pkg/metrics"
+	"github.com/nullify005/chat-hvac/pkg/scheduler"
 )
 
 type Receiver struct {
-	logger     *log.Logger
-	adapter    adapter.Adapter
-	shutdown   chan bool
-	signatures []ReceiverSignature
-	hvac       *hvac.Hvac
+	logger   *log.Logger
+	adapter  adapter.Adapter
+	shutdown chan bool
+	verbs    []ReceiverVerb
+	timeout  time.Duration
+	// hvac devices keyed by their friendly name
+	devices map[string]*hvac.Hvac
+	// the default device name for commands within a channel
+	channelDevices map[string]string
+	auth           *authorizer
+	confirm        *confirmations
+	scheduler      *scheduler.Scheduler
+	// where unprompted messages such as schedule outcomes are posted
+	channel string
+	// the time zone times of day given in commands are within
+	location *time.Location
+	presets  *presets
+	// status pollers keyed by device name
+	pollInterval time.Duration
+	pollers      map[string]*hvac.Poller
+	alerter      *alert.Alerter
+	metrics      *metrics.Metrics
+	history      *history.Store
+	thermostats  *thermostats
+	away         *away
+	audit        *audit.Log
+	// in flight handlers & the context they derive from, cancelled once the
+	// drain deadline passes on shutdown
+	inflight     *sync.WaitGroup
+	base         context.Context
+	cancel       context.CancelFunc
+	drainTimeout time.Duration
+	// posted to the channel when shutting down
+	offlineMessage string
 }
 
-// definition for what string to match on & then what action to take
-type ReceiverSignature struct {
-	signature *regexp.Regexp
-	handler   ReceiverHandler
+const (
+	defaultTimeout      time.Duration = 60 * time.Second
+	defaultDrainTimeout time.Duration = 20 * time.Second
+	// the role required to use the interactive control panel
+	actionRole Role = RoleOperator
+)
+
+// definition of a verb, how it's used & the handler which processes it
+type ReceiverVerb struct {
+	names   []string // the verb followed by any aliases
+	usage   string   // the arguments the verb accepts
+	minArgs int      // the minimum number of positional arguments
+	maxArgs int      // the maximum number of positional arguments, -1 for any
+	params  bool     // whether key=value arguments are accepted
+	role    Role     // the role required to use the verb
+	handler ReceiverHandler
+	// the role required by the command's arguments when they need more than
+	// role, eg. applying a preset needs an operator though listing doesn't
+	argRole func(c *Command) Role
+	// whether the command changes a device, which away mode reserves for
+	// admins. verbs with settings always do
+	changes func(c *Command) bool
+	// whether replies to a slash command are posted to the channel rather
+	// than only to the user, eg. for verbs which change a device
+	announce bool
+	// the device & key value pairs the command sets, verbs which have it may
+	// be deferred with a trailing `in <duration>` or `at <time>`
+	settings func(c *Command) (string, [][2]string, bool)
 }
 
-// generic defintion of a message handler once it's been matched against
-type ReceiverHandler func(r *Receiver, s *regexp.Regexp, e *adapter.Event)
+// generic defintion of a message handler once the verb has been matched
+// the context is cancelled once the handler deadline passes
+type ReceiverHandler func(ctx context.Context, r *Receiver, c *Command, e *adapter.Event)
+
+// runs actions from the interactive control panel, it isn't registered so
+// can't be sent as a message
+var actionVerb = ReceiverVerb{
+	names:   []string{"action"},
+	role:    actionRole,
+	handler: actionHandler,
+}
 
 type ReceiverOption func(r *Receiver)
 
@@ -34,9 +106,96 @@ func WithLogger(l *log.Logger) ReceiverOption {
 	}
 }
 
+// registers a single unnamed hvac device
 func WithHvac(h *hvac.Hvac) ReceiverOption {
+	return WithDevice(defaultDeviceName, h)
+}
+
+// registers a hvac device under a friendly name which commands can target
+func WithDevice(name string, h *hvac.Hvac) ReceiverOption {
+	return func(r *Receiver) {
+		r.devices[name] = h
+	}
+}
+
+// the device targeted by commands within channel when none is given
+func WithChannelDevice(channel, name string) ReceiverOption {
+	return func(r *Receiver) {
+		r.channelDevices[channel] = name
+	}
+}
+
+// grants a role to users, user groups & channels. once any role is granted
+// every verb requires the user to hold its role
+func WithGrant(role Role, g Grant) ReceiverOption {
+	return func(r *Receiver) {
+		r.auth.grants[role] = g
+	}
+}
+
+// the commands which must be confirmed before they run, replacing the
+// defaults. a pattern matches the start of a command eg. `set power off`
+func WithConfirm(patterns ...string) ReceiverOption {
+	return func(r *Receiver) {
+		r.confirm.setPatterns(patterns)
+	}
+}
+
+// how long a command awaits confirmation before it's cancelled
+func WithConfirmTimeout(t time.Duration) ReceiverOption {
+	return func(r *Receiver) {
+		r.confirm.timeout = t
+	}
+}
+
+// the channel unprompted messages are posted to, otherwise they're posted to
+// the channel the originating command came from
+func WithChannel(c string) ReceiverOption {
+	return func(r *Receiver) {
+		r.channel = c
+	}
+}
+
+// the scheduler which runs recurring sets managed via the schedule verb
+func WithScheduler(s *scheduler.Scheduler) ReceiverOption {
 	return func(r *Receiver) {
-		r.hvac = h
+		r.scheduler = s
+	}
+}
+
+// the time zone times of day such as `at 23:30` are within
+func WithLocation(l *time.Location) ReceiverOption {
+	return func(r *Receiver) {
+		r.location = l
+	}
+}
+
+// records events & commands as prometheus metrics, the device gauges are
+// recorded by the hvac client
+func WithMetrics(m *metrics.Metrics) ReceiverOption {
+	return func(r *Receiver) {
+		r.metrics = m
+	}
+}
+
+// how long in flight handlers are given to finish on shutdown
+func WithDrainTimeout(t time.Duration) ReceiverOption {
+	return func(r *Receiver) {
+		r.drainTimeout = t
+	}
+}
+
+// a message posted to the channel when shutting down, eg. going offline
+func WithOfflineMessage(m string) ReceiverOption {
+	return func(r *Receiver) {
+		r.offlineMessage = m
+	}
+}
+
+// the deadline for a handler to complete, including any calls to the hvac api
+func WithTimeout(t time.Duration) ReceiverOption {
+	return func(r *Receiver) {
+		r.timeout = t
 	}
 }
 
@@ -44,60 +203,309 @@ func WithHvac(h *hvac.Hvac) ReceiverOption {
 // TODO: refactor the hvac requirement & instead register custom handlers which have the logic present within them
 func New(a adapter.Adapter, opts ...ReceiverOption) Receiver {
 	r := &Receiver{
-		adapter:    a,
-		logger:     log.New(os.Stdout, "Receiver: ", log.Ldate|log.Ltime|log.Lshortfile),
-		shutdown:   make(chan bool, 1),
-		signatures: defaultSignatures(),
+		adapter:        a,
+		logger:         log.New(os.Stdout, "Receiver: ", log.Ldate|log.Ltime|log.Lshortfile),
+		shutdown:       make(chan bool, 1),
+		verbs:          defaultVerbs(),
+		timeout:        defaultTimeout,
+		devices:        map[string]*hvac.Hvac{},
+		channelDevices: map[string]string{},
+		auth:           newAuthorizer(),
+		confirm:        newConfirmations(),
+		location:       time.Local,
+		presets:        newPresets(),
+		pollers:        map[string]*hvac.Poller{},
+		thermostats:    &thermostats{byName: map[string]*hvac.Thermostat{}},
+		away:           &away{record: awayRecord{State: stateHome}},
+		inflight:       &sync.WaitGroup{},
+		drainTimeout:   defaultDrainTimeout,
 	}
+	r.base, r.cancel = context.WithCancel(context.Background())
 	for _, opt := range opts {
 		opt(r)
 	}
-	if r.hvac == nil {
-		r.hvac = hvac.New()
+	r.auth.logger = r.logger
+	if resolver, ok := a.(adapter.GroupResolver); ok {
+		r.auth.resolver = resolver
+	}
+	if !r.auth.enabled() {
+		r.logger.Print("no roles have been granted, everyone is allowed to use every verb")
 	}
+	if len(r.devices) == 0 {
+		r.devices[defaultDeviceName] = hvac.New()
+	}
+	r.newPollers()
 	return *r
 }
 
 // Start up the Listener & Receive events from it
-// Receive() is a blocking call which will only exist on a signal or
-// shutdown command via the Message
-func (r *Receiver) Receive() {
+// Receive() is a blocking call which will only exit once ctx is cancelled eg.
+// on a signal, or on a shutdown command via the Message. in flight handlers
+// are drained before it returns
+func (r *Receiver) Receive(ctx context.Context) {
 	r.logger.Print("launching event listener")
 	recv := make(chan adapter.Event)
 	r.adapter.Listen(recv)
-	go func() {
-		r.logger.Print("starting receiver")
-		for {
-			evt := <-recv
-			r.logger.Printf("received event: %v", evt)
-			handled := false
-			for _, sig := range r.signatures {
-				if sig.signature.Match([]byte(evt.Message)) {
-					sig.handler(r, sig.signature, &evt)
-					handled = true
-					break
-				}
-			}
-			if !handled {
-				r.logger.Printf("ignored unhandled event: %v", evt)
+	if err := r.presets.load(); err != nil {
+		r.logger.Printf("unable to load presets. cause: %v", err)
+	}
+	if err := r.thermostats.load(); err != nil {
+		r.logger.Printf("unable to load thermostats. cause: %v", err)
+	}
+	if err := r.away.load(); err != nil {
+		r.logger.Printf("unable to load away mode. cause: %v", err)
+	}
+	r.away.arm(r.returnOnTime)
+	r.startPollers(r.base)
+	if r.scheduler != nil {
+		if err := r.scheduler.Start(r.runSchedule, r.runTimer); err != nil {
+			r.logger.Printf("unable to start the scheduler. cause: %v", err)
+		}
+	}
+	r.logger.Print("starting receiver, awaiting shutdown signal|command")
+	for {
+		select {
+		case evt, ok := <-recv:
+			if !ok {
+				r.logger.Print("the listener has closed")
+				recv = nil
+				continue
 			}
+			r.logger.Printf("received event: %v", evt)
+			// handle concurrently so that a slow hvac api doesn't block other events
+			r.inflight.Add(1)
+			go func(evt adapter.Event) {
+				defer r.inflight.Done()
+				r.handle(evt)
+			}(evt)
+		case <-ctx.Done():
+			r.logger.Print("received shutdown signal")
+			r.stop()
+			return
+		case <-r.shutdown:
+			r.stop()
+			return
 		}
+	}
+}
+
+// stop listening & scheduling then wait for in flight handlers to finish,
+// cancelling any which are still running at the drain deadline
+func (r *Receiver) stop() {
+	r.logger.Print("shutting down")
+	r.adapter.Shutdown()
+	if r.scheduler != nil {
+		r.scheduler.Stop()
+	}
+	r.away.disarm()
+	done := make(chan struct{})
+	go func() {
+		r.inflight.Wait()
+		close(done)
 	}()
-	r.logger.Print("awaiting shutdown signal|command")
-	<-r.shutdown
+	select {
+	case <-done:
+		r.logger.Print("in flight handlers have finished")
+	case <-time.After(r.drainTimeout):
+		r.logger.Printf("in flight handlers didn't finish within %s, cancelling them", r.drainTimeout)
+	}
+	r.cancel()
+	if r.offlineMessage != "" && r.channel != "" {
+		r.adapter.Say(adapter.Message{Text: r.offlineMessage, Channel: r.channel})
+	}
+}
+
+// run the action handler for interactions, otherwise parse the event into a
+// command & run the handler of the matching verb
+func (r *Receiver) handle(evt adapter.Event) {
+	ctx, cancel := context.WithTimeout(r.base, r.timeout)
+	defer cancel()
+	r.metrics.Event(evt.Type)
+	if evt.Action != nil {
+		// treated as the equivalent set so that it's confirmed like one
+		c := &Command{
+			Verb: "set",
+			Args: []string{evt.Action.Device, evt.Action.Key, evt.Action.Value},
+			Raw:  fmt.Sprintf("set %s %s %s", evt.Action.Device, evt.Action.Key, evt.Action.Value),
+		}
+		if !r.authorized(ctx, actionRole, c, &evt) || r.awayRefused(ctx, c, &evt) {
+			return
+		}
+		if r.confirm.required(c) {
+			r.requestConfirmation(ctx, actionVerb, c, &evt)
+			return
+		}
+		r.run(ctx, actionVerb, c, &evt)
+		return
+	}
+	c, err := Parse(evt.Message)
+	switch {
+	case errors.Is(err, ErrEmptyCommand):
+		defaultHandler(ctx, r, &Command{}, &evt)
+		return
+	case err != nil:
+		r.logger.Printf("unable to parse event: %v cause: %v", evt, err)
+		parseErrorHandler(ctx, r, err, &evt)
+		return
+	}
+	v, ok := r.verb(c.Verb)
+	if !ok {
+		r.logger.Printf("ignored unknown verb: %s event: %v", c.Verb, evt)
+		defaultHandler(ctx, r, c, &evt)
+		return
+	}
+	// checked before anything else so that eg. a viewer isn't asked to confirm
+	// a preset they can't apply
+	if !r.authorized(ctx, v.roleFor(c), c, &evt) {
+		return
+	}
+	// commands which change settings are reserved for admins while away,
+	// which is also checked before confirmation
+	if v.changesDevice(c) && r.awayRefused(ctx, c, &evt) {
+		return
+	}
+	if v.settings != nil {
+		if c.When, err = splitWhen(c, time.Now().In(r.location)); err != nil {
+			r.logger.Printf("invalid time: %v event: %v", err, evt)
+			parseErrorHandler(ctx, r, err, &evt)
+			return
+		}
+	}
+	if err := v.check(c); err != nil {
+		r.logger.Printf("invalid usage: %v event: %v", err, evt)
+		usageHandler(ctx, r, c, &evt)
+		return
+	}
+	if r.confirmationRequired(v, c) {
+		r.requestConfirmation(ctx, v, c, &evt)
+		return
+	}
+	r.run(ctx, v, c, &evt)
+}
+
+// run the verb's handler, or start a timer when the command is deferred
+func (r *Receiver) run(ctx context.Context, v ReceiverVerb, c *Command, e *adapter.Event) {
+	if !c.When.IsZero() {
+		r.deferCommand(ctx, v, c, e)
+		return
+	}
+	start := time.Now()
+	v.handler(ctx, r, c, e)
+	r.metrics.Command(v.names[0], time.Since(start))
+}
+
+// whether the user behind the event holds the role, a refusal is sent when
+// they don't
+func (r *Receiver) authorized(ctx context.Context, role Role, c *Command, e *adapter.Event) bool {
+	held := r.auth.role(e.User, e.Channel)
+	if held >= role {
+		return true
+	}
+	r.logger.Printf("denied: %s user: %s channel: %s role: %s required: %s", c.Verb, e.User, e.Channel, held, role)
+	deniedHandler(ctx, r, role, c, e)
+	return false
+}
+
+// reply to the event with text, see respond
+func (r *Receiver) reply(e *adapter.Event, text string) {
+	r.respond(e, adapter.Message{Text: text})
+}
+
+// reply to the event with text which only the user sees when it's a slash
+// command, eg. errors, regardless of the verb
+func (r *Receiver) replyPrivately(e *adapter.Event, text string) {
+	r.respond(e, adapter.Message{Text: text, Ephemeral: true})
+}
+
+// send m where the event was sent, within its thread if it was sent in one.
+// slash commands are answered through their response url, which works in
+// channels the bot hasn't joined, & only the user sees the answer unless the
+// verb announces to the channel. interactions are always answered privately
+func (r *Receiver) respond(e *adapter.Event, m adapter.Message) {
+	m.Channel, m.User, m.ResponseURL = e.Channel, e.User, e.ResponseURL
+	if e.Thread != "" {
+		m.Threaded, m.Timestamp = true, e.Thread
+	}
+	switch e.Type {
+	case adapter.SlashCommandEvent:
+		m.Ephemeral = m.Ephemeral || !r.announces(e)
+	case adapter.InteractionEvent:
+		m.Ephemeral = true
+	default:
+		m.Ephemeral = false
+	}
+	r.adapter.Say(m)
+}
+
+// whether the verb of the event's command announces its replies
+func (r *Receiver) announces(e *adapter.Event) bool {
+	c, err := Parse(e.Message)
+	if err != nil {
+		return false
+	}
+	v, ok := r.verb(c.Verb)
+	return ok && v.announce
+}
+
+// the role required to run the command
+func (v ReceiverVerb) roleFor(c *Command) Role {
+	if v.argRole != nil {
+		return v.argRole(c)
+	}
+	return v.role
+}
+
+// whether the command changes a device
+func (v ReceiverVerb) changesDevice(c *Command) bool {
+	return v.settings != nil || (v.changes != nil && v.changes(c))
+}
+
+// the verb registered under name or one of its aliases
+func (r *Receiver) verb(name string) (ReceiverVerb, bool) {
+	for _, v := range r.verbs {
+		for _, n := range v.names {
+			if n == name {
+				return v, true
+			}
+		}
+	}
+	return ReceiverVerb{}, false
+}
+
+// whether the command satisfies the verb's usage
+func (v ReceiverVerb) check(c *Command) error {
+	switch {
+	case len(c.Args) < v.minArgs:
+		return &UsageError{Verb: c.Verb, Reason: "not enough arguments"}
+	case v.maxArgs >= 0 && len(c.Args) > v.maxArgs:
+		return &UsageError{Verb: c.Verb, Reason: "too many arguments"}
+	case !v.params && len(c.Params) > 0:
+		return &UsageError{Verb: c.Verb, Reason: "key=value arguments aren't supported"}
+	}
+	return nil
+}
+
+// the help text for the verb
+func (v ReceiverVerb) help() string {
+	names := strings.Join(v.names, "|")
+	if v.usage == "" {
+		return fmt.Sprintf("`@%s %s`", botName, names)
+	}
+	return fmt.Sprintf("`@%s %s %s`", botName, names, v.usage)
 }
 
 // shutdown the receiver & listener loop
 func (r *Receiver) Shutdown() {
-	r.logger.Print("shutting down")
-	r.adapter.Shutdown()
-	r.shutdown <- true
+	select {
+	case r.shutdown <- true:
+	default:
+		// already shutting down
+	}
 }
 
-// registers a new ReceiverSignature with the Reciever to iterate over
-// for when the bot is called. The order matters, the 1st registered
-// signature will be processed 1st etc.
-func (r *Receiver) RegisterSignature(s ReceiverSignature) {
-	r.logger.Printf("registering signature: %s", s.signature.String())
-	r.signatures = append(r.signatures, s)
+// registers a new ReceiverVerb with the Receiver. verbs are matched in
+// registration order, so the 1st verb registered with a name wins
+func (r *Receiver) RegisterVerb(v ReceiverVerb) {
+	r.logger.Printf("registering verb: %s", strings.Join(v.names, "|"))
+	r.verbs = append(r.verbs, v)
 }
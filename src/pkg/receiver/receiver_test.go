@@ -0,0 +1,148 @@
+package receiver
+
+import (
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nullify005/chat-hvac/pkg/adapter"
+	"github.com/nullify005/chat-hvac/pkg/hvac"
+)
+
+// an adapter which keeps what the receiver says
+type fakeAdapter struct {
+	mu   sync.Mutex
+	said []adapter.Message
+}
+
+func (a *fakeAdapter) Listen(chan adapter.Event) {}
+func (a *fakeAdapter) Shutdown()                 {}
+
+func (a *fakeAdapter) Say(m adapter.Message) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.said = append(a.said, m)
+}
+
+// the text of everything said since the last call
+func (a *fakeAdapter) flush() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	texts := []string{}
+	for _, m := range a.said {
+		texts = append(texts, m.Text)
+	}
+	a.said = nil
+	return texts
+}
+
+// a receiver with a viewer, an operator & an admin which doesn't log
+func testReceiver(t *testing.T, opts ...ReceiverOption) (*Receiver, *fakeAdapter) {
+	t.Helper()
+	a := &fakeAdapter{}
+	opts = append([]ReceiverOption{
+		WithLogger(log.New(io.Discard, "", 0)),
+		WithGrant(RoleViewer, Grant{Users: []string{"viewer"}}),
+		WithGrant(RoleOperator, Grant{Users: []string{"operator"}}),
+		WithGrant(RoleAdmin, Grant{Users: []string{"admin"}}),
+	}, opts...)
+	r := New(a, opts...)
+	return &r, a
+}
+
+// send the message as the user & return what was said in reply
+func send(r *Receiver, a *fakeAdapter, user, message string) []string {
+	r.handle(adapter.Event{User: user, Channel: "C1", Message: message})
+	return a.flush()
+}
+
+func wantReply(t *testing.T, message string, said []string, want string) {
+	t.Helper()
+	if len(said) != 1 || !strings.Contains(said[0], want) {
+		t.Errorf("%q replied %q, want %q", message, said, want)
+	}
+}
+
+func TestConfirmationNeedsTheRole(t *testing.T) {
+	r, a := testReceiver(t,
+		WithPreset("night", map[string]string{"power": "off"}),
+		WithConfirm("set power off"),
+	)
+	// a viewer is refused before being asked to confirm a preset they can't
+	// apply
+	said := send(r, a, "viewer", "preset night")
+	wantReply(t, "preset night", said, "you need the operator role")
+	said = send(r, a, "viewer", "yes")
+	wantReply(t, "yes", said, "nothing waiting for you to confirm")
+
+	said = send(r, a, "operator", "preset night")
+	wantReply(t, "preset night", said, "reply `yes`")
+	said = send(r, a, "operator", "no")
+	wantReply(t, "no", said, "cancelled `preset night`")
+}
+
+func TestAuthorized(t *testing.T) {
+	r, a := testReceiver(t)
+	tests := []struct {
+		user, message string
+		want          string // a refusal names the role, otherwise part of the reply
+	}{
+		{"stranger", "help", "you need the viewer role"},
+		{"viewer", "ping", "pong"},
+		{"viewer", "set power on", "you need the operator role"},
+		{"viewer", "off", "you need the operator role"},
+		{"viewer", "preset list", "no presets"},
+		{"viewer", "preset save night", "you need the operator role"},
+		{"viewer", "timer cancel t1", "you need the operator role"},
+		{"operator", "timer cancel t1", "Timers aren't enabled"},
+		{"viewer", "schedule remove s1", "you need the operator role"},
+		{"viewer", "thermostat", "The thermostat isn't enabled"},
+		{"viewer", "thermostat 20-24", "you need the operator role"},
+		{"viewer", "away cancel", "you need the operator role"},
+		{"operator", "shutdown", "you need the admin role"},
+	}
+	for _, tt := range tests {
+		said := send(r, a, tt.user, tt.message)
+		wantReply(t, tt.user+": "+tt.message, said, tt.want)
+	}
+}
+
+func TestAwayRefused(t *testing.T) {
+	r, a := testReceiver(t, WithPreset("night", map[string]string{"power": "off"}))
+	r.thermostats.byName[defaultDeviceName] = hvac.NewThermostat(r.devices[defaultDeviceName])
+	r.away.record = awayRecord{State: stateAway, Until: time.Now().Add(time.Hour)}
+
+	for _, message := range []string{"set power on", "on", "preset night", "thermostat 20-24", "thermostat off"} {
+		said := send(r, a, "operator", message)
+		wantReply(t, message, said, "only admins can change settings")
+	}
+	// reading is still open to everyone who could before
+	said := send(r, a, "viewer", "thermostat")
+	wantReply(t, "thermostat", said, "thermostat")
+
+	said = send(r, a, "admin", "thermostat off")
+	wantReply(t, "thermostat off", said, "the thermostat is off")
+}
+
+func TestConfirmationExpires(t *testing.T) {
+	r, a := testReceiver(t, WithConfirm("set power off"), WithConfirmTimeout(10*time.Millisecond))
+	said := send(r, a, "operator", "off")
+	wantReply(t, "off", said, "reply `yes`")
+	// another user can't confirm it for them
+	said = send(r, a, "admin", "yes")
+	wantReply(t, "yes", said, "nothing waiting for you to confirm")
+
+	deadline := time.Now().Add(time.Second)
+	for len(said) == 0 || !strings.Contains(said[0], "wasn't confirmed in time") {
+		if time.Now().After(deadline) {
+			t.Fatalf("the confirmation didn't expire, said %q", said)
+		}
+		time.Sleep(5 * time.Millisecond)
+		said = a.flush()
+	}
+	said = send(r, a, "operator", "yes")
+	wantReply(t, "yes", said, "nothing waiting for you to confirm")
+}
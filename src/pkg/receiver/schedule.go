@@ -0,0 +1,138 @@
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nullify005/chat-hvac/pkg/adapter"
+	"github.com/nullify005/chat-hvac/pkg/audit"
+	"github.com/nullify005/chat-hvac/pkg/presenter"
+	"github.com/nullify005/chat-hvac/pkg/scheduler"
+)
+
+const scheduleUsage string = "add \"<cron>\" set [device|all] <key> <value> | list | remove <id>"
+
+// listing the schedules is open to viewers while changes require an operator
+func scheduleRole(c *Command) Role {
+	switch strings.ToLower(c.Arg(0)) {
+	case "add", "remove", "rm", "delete":
+		return RoleOperator
+	}
+	return RoleViewer
+}
+
+// manage the recurring schedules
+func scheduleHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	if r.scheduler == nil {
+		r.reply(e, "Scheduling isn't enabled. :calendar:")
+		return
+	}
+	var reply string
+	switch strings.ToLower(c.Arg(0)) {
+	case "add":
+		reply = r.addSchedule(c, e)
+	case "remove", "rm", "delete":
+		reply = r.removeSchedule(c, e)
+	case "list", "ls", "":
+		reply = presenter.Schedules(r.scheduler.List())
+	default:
+		usageHandler(ctx, r, c, e)
+		return
+	}
+	r.reply(e, reply)
+}
+
+// schedule add "<cron>" set [device] key value
+func (r *Receiver) addSchedule(c *Command, e *adapter.Event) string {
+	if len(c.Args) < 3 || strings.ToLower(c.Args[2]) != "set" {
+		return fmt.Sprintf(":x: usage: `@%s schedule %s`", botName, scheduleUsage)
+	}
+	device, params, ok := setArgs(&Command{Verb: "set", Args: c.Args[3:], Params: c.Params})
+	if !ok {
+		return fmt.Sprintf(":x: usage: `@%s schedule %s`", botName, scheduleUsage)
+	}
+	// resolve now so that an unknown device is rejected up front
+	targets, err := r.targets(device, e.Channel)
+	if err != nil {
+		return presenter.Error(err)
+	}
+	if len(targets) == 1 {
+		device = targets[0].name
+	}
+	sc := scheduler.Schedule{
+		Spec:    c.Args[1],
+		Device:  device,
+		User:    e.User,
+		Channel: e.Channel,
+	}
+	for _, p := range params {
+		sc.Settings = append(sc.Settings, scheduler.Setting{Key: p[0], Value: p[1]})
+	}
+	added, err := r.scheduler.Add(sc)
+	if err != nil {
+		r.logger.Printf("unable to add schedule: %+v cause: %v", sc, err)
+		return presenter.Error(err)
+	}
+	r.logger.Printf("added schedule: %+v user: %s", added, e.User)
+	return fmt.Sprintf(":calendar: added %s", presenter.Schedule(added))
+}
+
+// schedule remove <id>
+func (r *Receiver) removeSchedule(c *Command, e *adapter.Event) string {
+	if len(c.Args) != 2 {
+		return fmt.Sprintf(":x: usage: `@%s schedule %s`", botName, scheduleUsage)
+	}
+	sc, err := r.scheduler.Remove(c.Args[1])
+	if err != nil {
+		return presenter.Error(err)
+	}
+	r.logger.Printf("removed schedule: %+v user: %s", sc, e.User)
+	return fmt.Sprintf(":wastebasket: removed %s", presenter.Schedule(sc))
+}
+
+// apply a due schedule to its device(s) & report the outcome. devices which
+// can't be reached are skipped rather than partially applied
+func (r *Receiver) runSchedule(sc scheduler.Schedule) {
+	ctx, cancel := context.WithTimeout(r.base, r.timeout)
+	defer cancel()
+	channel := r.channel
+	if channel == "" {
+		channel = sc.Channel
+	}
+	if record, ok := r.away.active(); ok {
+		r.logger.Printf("skipping schedule: %s while away", sc.ID)
+		r.adapter.Say(adapter.Message{Text: presenter.ScheduleRun(sc, presenter.AwaySkipped(record.Until.In(r.location))), Channel: channel})
+		return
+	}
+	targets, err := r.targets(sc.Device, sc.Channel)
+	if err != nil {
+		r.logger.Printf("schedule: %s failed cause: %v", sc.ID, err)
+		r.adapter.Say(adapter.Message{Text: presenter.ScheduleRun(sc, presenter.Error(err)), Channel: channel})
+		return
+	}
+	reply := r.fanOut(ctx, targets, func(ctx context.Context, t target) string {
+		if _, err := t.hvac.Status(ctx); err != nil {
+			r.logger.Printf("skipping schedule: %s device: %s cause: %v", sc.ID, t.name, err)
+			return fmt.Sprintf(":fast_forward: skipped, the device is unreachable. %s", presenter.Error(err))
+		}
+		replies := []string{}
+		changes := []audit.Change{}
+		defer func() {
+			command := &Command{Verb: "schedule", Args: []string{sc.ID}, Raw: fmt.Sprintf("schedule %s", sc.ID)}
+			r.record(&adapter.Event{User: sc.User, Channel: sc.Channel}, command, t, changes, nil)
+		}()
+		for _, s := range sc.Settings {
+			res, err := t.hvac.Set(ctx, s.Key, s.Value)
+			changes = append(changes, audit.SetChange(s.Key, s.Value, res, err))
+			if err != nil {
+				r.logger.Printf("schedule: %s set failed. device: %s cause: %v", sc.ID, t.name, err)
+				replies = append(replies, presenter.Error(err))
+				continue
+			}
+			replies = append(replies, presenter.Set(res))
+		}
+		return strings.Join(replies, "\n")
+	})
+	r.adapter.Say(adapter.Message{Text: presenter.ScheduleRun(sc, reply), Channel: channel})
+}
@@ -0,0 +1,159 @@
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nullify005/chat-hvac/pkg/adapter"
+	"github.com/nullify005/chat-hvac/pkg/hvac"
+	"github.com/nullify005/chat-hvac/pkg/presenter"
+	"github.com/nullify005/chat-hvac/pkg/store"
+)
+
+const thermostatUsage string = "[device|all] [<low>-<high>|off]"
+
+// the thermostat of each device & where their bands are persisted
+type thermostats struct {
+	mu     sync.Mutex
+	path   string
+	opts   []hvac.ThermostatOption
+	byName map[string]*hvac.Thermostat
+}
+
+// tune the thermostat of every device, thermostats require polling
+func WithThermostat(opts ...hvac.ThermostatOption) ReceiverOption {
+	return func(r *Receiver) {
+		r.thermostats.opts = append(r.thermostats.opts, opts...)
+	}
+}
+
+// the json file the bands of enabled thermostats are persisted to, when
+// empty thermostats are disabled on restart
+func WithThermostatPath(p string) ReceiverOption {
+	return func(r *Receiver) {
+		r.thermostats.path = p
+	}
+}
+
+// re-enable the thermostats which were enabled when last persisted
+func (t *thermostats) load() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.path == "" {
+		return nil
+	}
+	bands := map[string]hvac.Band{}
+	if err := store.Load(t.path, &bands); err != nil {
+		return err
+	}
+	for name, b := range bands {
+		if th, ok := t.byName[name]; ok {
+			if err := th.Enable(b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// save the band of every enabled thermostat
+func (t *thermostats) persist() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.path == "" {
+		return nil
+	}
+	bands := map[string]hvac.Band{}
+	for name, th := range t.byName {
+		if b, ok := th.Band(); ok {
+			bands[name] = b
+		}
+	}
+	return store.Save(t.path, bands)
+}
+
+// split the target device(s) from a trailing band or `off`
+func thermostatArgs(c *Command) ([]string, string) {
+	if len(c.Args) > 0 {
+		if last := strings.ToLower(c.Args[len(c.Args)-1]); last == "off" || strings.Contains(last, "-") {
+			return c.Args[:len(c.Args)-1], last
+		}
+	}
+	return c.Args, ""
+}
+
+// enabling the thermostat within a band or disabling it requires an operator
+func thermostatRole(c *Command) Role {
+	if thermostatChanges(c) {
+		return RoleOperator
+	}
+	return RoleViewer
+}
+
+// whether the command enables or disables the thermostat, which changes how
+// the device is run
+func thermostatChanges(c *Command) bool {
+	_, arg := thermostatArgs(c)
+	return arg != ""
+}
+
+// show the thermostat of the target device(s), or enable or disable it
+func thermostatHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	if len(r.thermostats.byName) == 0 {
+		r.reply(e, "The thermostat isn't enabled, it needs polling. :thermometer:")
+		return
+	}
+	args, arg := thermostatArgs(c)
+	var band hvac.Band
+	if arg != "" && arg != "off" {
+		var err error
+		if band, err = ParseBand(arg); err != nil {
+			parseErrorHandler(ctx, r, err, e)
+			return
+		}
+	}
+	reply := r.forTargets(ctx, strings.Join(args, " "), e.Channel, func(ctx context.Context, t target) string {
+		th := r.thermostats.byName[t.name]
+		switch arg {
+		case "":
+			b, enabled := th.Band()
+			last := th.Last()
+			last.Time = last.Time.In(r.location)
+			return presenter.Thermostat(b, enabled, last)
+		case "off":
+			th.Disable()
+			r.logger.Printf("thermostat disabled. device: %s user: %s", t.name, e.User)
+			return ":thermometer: the thermostat is off, the device is left as it is"
+		}
+		if err := th.Enable(band); err != nil {
+			return presenter.Error(err)
+		}
+		r.logger.Printf("thermostat enabled. device: %s band: %.1f-%.1f user: %s", t.name, band.Low, band.High, e.User)
+		return fmt.Sprintf(":thermometer: I'll keep the room between %s & %s, checking every %s", presenter.Temperature(band.Low), presenter.Temperature(band.High), presenter.Duration(r.pollInterval))
+	})
+	if arg != "" {
+		if err := r.thermostats.persist(); err != nil {
+			r.logger.Printf("unable to persist thermostats. cause: %v", err)
+		}
+	}
+	r.reply(e, reply)
+}
+
+// a band such as 21-24 or 21.5-23.5
+func ParseBand(expr string) (hvac.Band, error) {
+	low, high, ok := strings.Cut(expr, "-")
+	if ok {
+		l, lerr := strconv.ParseFloat(low, 64)
+		h, herr := strconv.ParseFloat(high, 64)
+		if lerr == nil && herr == nil {
+			if l >= h {
+				return hvac.Band{}, fmt.Errorf("the band `%s` must be low to high, eg. 21-24", expr)
+			}
+			return hvac.Band{Low: l, High: h}, nil
+		}
+	}
+	return hvac.Band{}, fmt.Errorf("I don't understand the band `%s`, try something like 21-24", expr)
+}
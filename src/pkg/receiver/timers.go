@@ -0,0 +1,139 @@
+package receiver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nullify005/chat-hvac/pkg/adapter"
+	"github.com/nullify005/chat-hvac/pkg/presenter"
+	"github.com/nullify005/chat-hvac/pkg/scheduler"
+)
+
+const timerUsage string = "list | cancel <id>"
+
+// how late a timer may fire before the outcome mentions it was delayed
+const timerLateness time.Duration = time.Minute
+
+// cancelling a timer requires an operator
+func timerRole(c *Command) Role {
+	switch strings.ToLower(c.Arg(0)) {
+	case "cancel", "remove", "rm", "delete":
+		return RoleOperator
+	}
+	return RoleViewer
+}
+
+// list the pending timers or cancel one
+func timerHandler(ctx context.Context, r *Receiver, c *Command, e *adapter.Event) {
+	if r.scheduler == nil {
+		r.reply(e, "Timers aren't enabled. :hourglass:")
+		return
+	}
+	var reply string
+	switch strings.ToLower(c.Arg(0)) {
+	case "cancel", "remove", "rm", "delete":
+		if len(c.Args) != 2 {
+			usageHandler(ctx, r, c, e)
+			return
+		}
+		t, err := r.scheduler.CancelTimer(c.Args[1])
+		if err != nil {
+			reply = presenter.Error(err)
+			break
+		}
+		r.logger.Printf("cancelled timer: %+v user: %s", t, e.User)
+		reply = fmt.Sprintf(":wastebasket: cancelled %s", presenter.Timer(t))
+	case "list", "ls", "":
+		reply = presenter.Timers(r.scheduler.Timers())
+	default:
+		usageHandler(ctx, r, c, e)
+		return
+	}
+	r.reply(e, reply)
+}
+
+// start a timer which applies the command's settings when it's due, the
+// outcome is posted in the thread of the original message
+func (r *Receiver) deferCommand(ctx context.Context, v ReceiverVerb, c *Command, e *adapter.Event) {
+	reply := r.addTimer(v, c, e)
+	r.reply(e, reply)
+}
+
+// add a timer for the command, returning the reply for the user
+func (r *Receiver) addTimer(v ReceiverVerb, c *Command, e *adapter.Event) string {
+	if r.scheduler == nil {
+		return "Timers aren't enabled. :hourglass:"
+	}
+	device, params, ok := v.settings(c)
+	if !ok {
+		return fmt.Sprintf(":x: usage: %s", v.help())
+	}
+	// resolve now so that an unknown device is rejected up front
+	targets, err := r.targets(device, e.Channel)
+	if err != nil {
+		return presenter.Error(err)
+	}
+	if len(targets) == 1 {
+		device = targets[0].name
+	}
+	thread := e.Thread
+	if thread == "" {
+		thread = e.Timestamp
+	}
+	t := scheduler.Timer{
+		At:      c.When,
+		Device:  device,
+		User:    e.User,
+		Channel: e.Channel,
+		Thread:  thread,
+	}
+	for _, p := range params {
+		t.Settings = append(t.Settings, scheduler.Setting{Key: p[0], Value: p[1]})
+	}
+	added, err := r.scheduler.AddTimer(t)
+	if err != nil {
+		r.logger.Printf("unable to add timer: %+v cause: %v", t, err)
+		return presenter.Error(err)
+	}
+	r.logger.Printf("added timer: %+v user: %s", added, e.User)
+	return fmt.Sprintf(":hourglass_flowing_sand: OK, in %s %s", presenter.Duration(time.Until(added.At)), presenter.Timer(added))
+}
+
+// apply a due timer to its device(s) & report the outcome in its thread
+func (r *Receiver) runTimer(t scheduler.Timer) {
+	ctx, cancel := context.WithTimeout(r.base, r.timeout)
+	defer cancel()
+	m := adapter.Message{
+		Channel:   t.Channel,
+		Threaded:  t.Thread != "",
+		Timestamp: t.Thread,
+	}
+	if record, ok := r.away.active(); ok {
+		r.logger.Printf("skipping timer: %s while away", t.ID)
+		m.Text = presenter.TimerRun(t, presenter.AwaySkipped(record.Until.In(r.location)))
+		r.adapter.Say(m)
+		return
+	}
+	targets, err := r.targets(t.Device, t.Channel)
+	if err != nil {
+		r.logger.Printf("timer: %s failed cause: %v", t.ID, err)
+		m.Text = presenter.TimerRun(t, presenter.Error(err))
+		r.adapter.Say(m)
+		return
+	}
+	params := [][2]string{}
+	for _, s := range t.Settings {
+		params = append(params, [2]string{s.Key, s.Value})
+	}
+	command := &Command{Verb: "timer", Args: []string{t.ID}, Raw: fmt.Sprintf("timer %s", t.ID)}
+	reply := r.fanOut(ctx, targets, func(ctx context.Context, target target) string {
+		return r.apply(ctx, &adapter.Event{User: t.User, Channel: t.Channel}, command, target, params)
+	})
+	if late := time.Since(t.At); late > timerLateness {
+		reply = fmt.Sprintf("I was offline when it was due so it's %s late.\n%s", presenter.Duration(late), reply)
+	}
+	m.Text = presenter.TimerRun(t, reply)
+	r.adapter.Say(m)
+}
@@ -0,0 +1,102 @@
+package receiver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the keywords which introduce a relative or absolute time
+const (
+	whenIn string = "in"
+	whenAt string = "at"
+)
+
+// the accepted formats for an absolute time, those without a date are the
+// next occurrence of that time of day
+var (
+	timeOfDayFormats = []string{"15:04", "3pm", "3:04pm", "3PM", "3:04PM"}
+	dateTimeFormats  = []string{"2006-01-02 15:04", "2006-01-02T15:04"}
+	durationPattern  = regexp.MustCompile(`^(\d+)\s*(s|secs?|seconds?|m|mins?|minutes?|h|hrs?|hours?|d|days?)$`)
+)
+
+// removes a trailing `in <duration>` or `at <time>` from the command's
+// arguments & returns when it refers to. a zero time means there wasn't one
+func splitWhen(c *Command, now time.Time) (time.Time, error) {
+	for i := len(c.Args) - 2; i >= 0; i-- {
+		keyword := strings.ToLower(c.Args[i])
+		if keyword != whenIn && keyword != whenAt {
+			continue
+		}
+		expr := strings.Join(c.Args[i+1:], " ")
+		var (
+			when time.Time
+			err  error
+		)
+		if keyword == whenIn {
+			var d time.Duration
+			d, err = parseDuration(expr)
+			when = now.Add(d)
+		} else {
+			when, err = parseTime(expr, now)
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+		c.Args = c.Args[:i]
+		return when, nil
+	}
+	return time.Time{}, nil
+}
+
+// a go duration such as 1h30m or a count & unit such as `2 hours`
+func parseDuration(expr string) (time.Duration, error) {
+	if d, err := time.ParseDuration(strings.ReplaceAll(expr, " ", "")); err == nil && d > 0 {
+		return d, nil
+	}
+	m := durationPattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(expr)))
+	n := 0
+	if m != nil {
+		n, _ = strconv.Atoi(m[1])
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("I don't understand `in %s`, try something like `in 2h` or `in 30 minutes`", expr)
+	}
+	unit := time.Second
+	switch m[2][0] {
+	case 'm':
+		unit = time.Minute
+	case 'h':
+		unit = time.Hour
+	case 'd':
+		unit = 24 * time.Hour
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// an absolute date & time, or the next occurrence of a time of day, in the
+// location of now
+func parseTime(expr string, now time.Time) (time.Time, error) {
+	for _, f := range dateTimeFormats {
+		if t, err := time.ParseInLocation(f, expr, now.Location()); err == nil {
+			if !t.After(now) {
+				return time.Time{}, fmt.Errorf("`%s` is in the past", expr)
+			}
+			return t, nil
+		}
+	}
+	for _, f := range timeOfDayFormats {
+		t, err := time.ParseInLocation(f, expr, now.Location())
+		if err != nil {
+			continue
+		}
+		when := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+		if !when.After(now) {
+			when = when.AddDate(0, 0, 1)
+		}
+		return when, nil
+	}
+	return time.Time{}, fmt.Errorf("I don't understand `at %s`, try something like `at 23:30`, `at 7am` or `at 2026-11-02 07:00`", expr)
+}
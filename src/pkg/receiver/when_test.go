@@ -0,0 +1,119 @@
+package receiver
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitWhen(t *testing.T) {
+	now := time.Date(2026, 3, 14, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		args []string
+		want time.Time
+		rest []string
+		err  bool
+	}{
+		{"none", []string{"power", "off"}, time.Time{}, []string{"power", "off"}, false},
+		{"in", []string{"power", "off", "in", "2h"}, now.Add(2 * time.Hour), []string{"power", "off"}, false},
+		{"in with a spaced unit", []string{"power", "off", "in", "30", "minutes"}, now.Add(30 * time.Minute), []string{"power", "off"}, false},
+		{"at", []string{"power", "on", "at", "7am"}, time.Date(2026, 3, 15, 7, 0, 0, 0, time.UTC), []string{"power", "on"}, false},
+		{"at a date", []string{"power", "on", "AT", "2026-03-20", "06:30"}, time.Date(2026, 3, 20, 6, 30, 0, 0, time.UTC), []string{"power", "on"}, false},
+		{"keyword without an expression", []string{"mode", "in"}, time.Time{}, []string{"mode", "in"}, false},
+		{"last keyword wins", []string{"power", "in", "at", "11:00"}, time.Date(2026, 3, 14, 11, 0, 0, 0, time.UTC), []string{"power", "in"}, false},
+		{"invalid duration", []string{"power", "off", "in", "a", "while"}, time.Time{}, []string{"power", "off", "in", "a", "while"}, true},
+		{"invalid time", []string{"power", "off", "at", "noon"}, time.Time{}, []string{"power", "off", "at", "noon"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Command{Verb: "set", Args: append([]string{}, tt.args...)}
+			got, err := splitWhen(c, now)
+			if (err != nil) != tt.err {
+				t.Fatalf("splitWhen(%q) error = %v, want error %t", tt.args, err, tt.err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("splitWhen(%q) = %s, want %s", tt.args, got, tt.want)
+			}
+			if !reflect.DeepEqual(c.Args, tt.rest) {
+				t.Errorf("splitWhen(%q) left args %q, want %q", tt.args, c.Args, tt.rest)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		expr string
+		want time.Duration
+		err  bool
+	}{
+		{"90s", 90 * time.Second, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"1h 30m", 90 * time.Minute, false},
+		{"45 secs", 45 * time.Second, false},
+		{"1 second", time.Second, false},
+		{"5m", 5 * time.Minute, false},
+		{"10 mins", 10 * time.Minute, false},
+		{"2 Hours", 2 * time.Hour, false},
+		{"3 hr", 3 * time.Hour, false},
+		{"1 day", 24 * time.Hour, false},
+		{"2d", 48 * time.Hour, false},
+		{"-5m", 0, true},
+		{"0m", 0, true},
+		{"0 minutes", 0, true},
+		{"00 hours", 0, true},
+		{"5", 0, true},
+		{"five minutes", 0, true},
+		{"2 weeks", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDuration(tt.expr)
+		if (err != nil) != tt.err {
+			t.Errorf("parseDuration(%q) error = %v, want error %t", tt.expr, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseDuration(%q) = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	now := time.Date(2026, 3, 14, 10, 0, 0, 0, sydney)
+	tests := []struct {
+		expr string
+		want time.Time
+		err  bool
+	}{
+		{"23:30", time.Date(2026, 3, 14, 23, 30, 0, 0, sydney), false},
+		{"10:00", time.Date(2026, 3, 15, 10, 0, 0, 0, sydney), false},
+		{"09:59", time.Date(2026, 3, 15, 9, 59, 0, 0, sydney), false},
+		{"10:01", time.Date(2026, 3, 14, 10, 1, 0, 0, sydney), false},
+		{"7am", time.Date(2026, 3, 15, 7, 0, 0, 0, sydney), false},
+		{"3pm", time.Date(2026, 3, 14, 15, 0, 0, 0, sydney), false},
+		{"3:45pm", time.Date(2026, 3, 14, 15, 45, 0, 0, sydney), false},
+		{"11PM", time.Date(2026, 3, 14, 23, 0, 0, 0, sydney), false},
+		{"2026-03-14 10:01", time.Date(2026, 3, 14, 10, 1, 0, 0, sydney), false},
+		{"2026-11-02T07:00", time.Date(2026, 11, 2, 7, 0, 0, 0, sydney), false},
+		{"2026-03-14 10:00", time.Time{}, true},
+		{"2025-12-25 07:00", time.Time{}, true},
+		{"25:00", time.Time{}, true},
+		{"tomorrow", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseTime(tt.expr, now)
+		if (err != nil) != tt.err {
+			t.Errorf("parseTime(%q) error = %v, want error %t", tt.expr, err, tt.err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseTime(%q) = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
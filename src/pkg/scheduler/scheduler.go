@@ -0,0 +1,232 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nullify005/chat-hvac/pkg/store"
+	"github.com/robfig/cron/v3"
+)
+
+// a single key & value to set on a device
+type Setting struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// a recurring set of a device, Spec is a standard 5 field cron expression
+// which may be prefixed with CRON_TZ=<zone>
+type Schedule struct {
+	ID       string    `json:"id"`
+	Spec     string    `json:"spec"`
+	Device   string    `json:"device"`
+	Settings []Setting `json:"settings"`
+	User     string    `json:"user"`
+	Channel  string    `json:"channel"`
+	Created  time.Time `json:"created"`
+	Next     time.Time `json:"-"`
+}
+
+// called each time a schedule is due
+type Job func(s Schedule)
+
+type Scheduler struct {
+	logger    *log.Logger
+	path      string
+	location  *time.Location
+	cron      *cron.Cron
+	mu        sync.Mutex
+	schedules map[string]*Schedule
+	entries   map[string]cron.EntryID
+	lastID    int
+	job       Job
+	// one off timers & where they're persisted
+	timerPath   string
+	timers      map[string]*Timer
+	clocks      map[string]*time.Timer
+	lastTimerID int
+	timerJob    TimerJob
+}
+
+type SchedulerOption func(s *Scheduler)
+
+func WithLogger(l *log.Logger) SchedulerOption {
+	return func(s *Scheduler) {
+		s.logger = l
+	}
+}
+
+// the json file the schedules are persisted to, when empty they're lost on restart
+func WithPath(p string) SchedulerOption {
+	return func(s *Scheduler) {
+		s.path = p
+	}
+}
+
+// the time zone of schedules which don't set their own CRON_TZ
+func WithLocation(l *time.Location) SchedulerOption {
+	return func(s *Scheduler) {
+		s.location = l
+	}
+}
+
+func New(opts ...SchedulerOption) *Scheduler {
+	s := &Scheduler{
+		logger:    log.New(os.Stdout, "Scheduler: ", log.Ldate|log.Ltime|log.Lshortfile),
+		location:  time.Local,
+		schedules: map[string]*Schedule{},
+		entries:   map[string]cron.EntryID{},
+		timers:    map[string]*Timer{},
+		clocks:    map[string]*time.Timer{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.cron = cron.New(cron.WithLocation(s.location))
+	return s
+}
+
+// load the persisted schedules & timers then start running job as each
+// schedule becomes due & timerJob as each timer does
+func (s *Scheduler) Start(job Job, timerJob TimerJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.job = job
+	persisted := []Schedule{}
+	if s.path != "" {
+		if err := store.Load(s.path, &persisted); err != nil {
+			return fmt.Errorf("unable to load schedules from: %s cause: %w", s.path, err)
+		}
+	} else {
+		s.logger.Print("no state directory configured, schedules & timers won't survive a restart")
+	}
+	for i := range persisted {
+		sc := persisted[i]
+		if err := s.register(&sc); err != nil {
+			s.logger.Printf("dropping invalid schedule: %+v cause: %v", sc, err)
+		}
+	}
+	s.logger.Printf("loaded %d schedules", len(s.schedules))
+	if err := s.startTimers(timerJob); err != nil {
+		return err
+	}
+	s.cron.Start()
+	return nil
+}
+
+// stop scheduling, returning once any running jobs complete. pending timers
+// are left persisted to fire after a restart
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	for _, c := range s.clocks {
+		c.Stop()
+	}
+	s.mu.Unlock()
+	<-s.cron.Stop().Done()
+}
+
+// validate, register & persist a new schedule, the ID is assigned
+func (s *Scheduler) Add(sc Schedule) (Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc.ID = strconv.Itoa(s.lastID + 1)
+	sc.Created = time.Now()
+	if err := s.register(&sc); err != nil {
+		return Schedule{}, err
+	}
+	if err := s.save(); err != nil {
+		s.unregister(sc.ID)
+		return Schedule{}, err
+	}
+	sc.Next = s.cron.Entry(s.entries[sc.ID]).Next
+	return sc, nil
+}
+
+// remove & persist the removal of a schedule
+func (s *Scheduler) Remove(id string) (Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, ok := s.schedules[id]
+	if !ok {
+		return Schedule{}, fmt.Errorf("no schedule with id: %s", id)
+	}
+	s.unregister(id)
+	if err := s.save(); err != nil {
+		return Schedule{}, err
+	}
+	return *sc, nil
+}
+
+// every schedule ordered by id, with the time of its next run
+func (s *Scheduler) List() []Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []Schedule{}
+	for id, sc := range s.schedules {
+		c := *sc
+		c.Next = s.cron.Entry(s.entries[id]).Next
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, _ := strconv.Atoi(list[i].ID)
+		b, _ := strconv.Atoi(list[j].ID)
+		return a < b
+	})
+	return list
+}
+
+// add the schedule to cron, the caller must hold the lock
+func (s *Scheduler) register(sc *Schedule) error {
+	id, err := s.cron.AddFunc(sc.Spec, s.run(sc.ID))
+	if err != nil {
+		return fmt.Errorf("invalid schedule: `%s` cause: %w", sc.Spec, err)
+	}
+	s.schedules[sc.ID] = sc
+	s.entries[sc.ID] = id
+	if n, err := strconv.Atoi(sc.ID); err == nil && n > s.lastID {
+		s.lastID = n
+	}
+	return nil
+}
+
+// remove the schedule from cron, the caller must hold the lock
+func (s *Scheduler) unregister(id string) {
+	s.cron.Remove(s.entries[id])
+	delete(s.entries, id)
+	delete(s.schedules, id)
+}
+
+// persist the schedules, the caller must hold the lock
+func (s *Scheduler) save() error {
+	if s.path == "" {
+		return nil
+	}
+	list := []Schedule{}
+	for _, sc := range s.schedules {
+		list = append(list, *sc)
+	}
+	return store.Save(s.path, list)
+}
+
+// the cron func for the schedule with id
+func (s *Scheduler) run(id string) func() {
+	return func() {
+		s.mu.Lock()
+		sc, ok := s.schedules[id]
+		var c Schedule
+		if ok {
+			c = *sc
+		}
+		s.mu.Unlock()
+		if !ok {
+			return
+		}
+		s.logger.Printf("running schedule: %s spec: %s", c.ID, c.Spec)
+		s.job(c)
+	}
+}
@@ -0,0 +1,149 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/nullify005/chat-hvac/pkg/store"
+)
+
+// a one off set of a device at a point in time, Thread is where the
+// outcome is posted
+type Timer struct {
+	ID       string    `json:"id"`
+	At       time.Time `json:"at"`
+	Device   string    `json:"device"`
+	Settings []Setting `json:"settings"`
+	User     string    `json:"user"`
+	Channel  string    `json:"channel"`
+	Thread   string    `json:"thread"`
+	Created  time.Time `json:"created"`
+}
+
+// called when a timer is due
+type TimerJob func(t Timer)
+
+// the json file the timers are persisted to, when empty they're lost on restart
+func WithTimerPath(p string) SchedulerOption {
+	return func(s *Scheduler) {
+		s.timerPath = p
+	}
+}
+
+// load the persisted timers & arm them, any which fell due while we were
+// down fire straight away
+func (s *Scheduler) startTimers(job TimerJob) error {
+	s.timerJob = job
+	persisted := []Timer{}
+	if s.timerPath != "" {
+		if err := store.Load(s.timerPath, &persisted); err != nil {
+			return fmt.Errorf("unable to load timers from: %s cause: %w", s.timerPath, err)
+		}
+	}
+	for i := range persisted {
+		s.arm(&persisted[i])
+	}
+	s.logger.Printf("loaded %d timers", len(s.timers))
+	return nil
+}
+
+// arm & persist a new timer, the ID is assigned
+func (s *Scheduler) AddTimer(t Timer) (Timer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !t.At.After(time.Now()) {
+		return Timer{}, fmt.Errorf("the time `%s` has already passed", t.At.Format(time.RFC1123))
+	}
+	t.ID = strconv.Itoa(s.lastTimerID + 1)
+	t.Created = time.Now()
+	s.arm(&t)
+	if err := s.saveTimers(); err != nil {
+		s.disarm(t.ID)
+		return Timer{}, err
+	}
+	return t, nil
+}
+
+// cancel & persist the removal of a pending timer
+func (s *Scheduler) CancelTimer(id string) (Timer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.timers[id]
+	if !ok {
+		return Timer{}, fmt.Errorf("no pending timer with id: %s", id)
+	}
+	s.disarm(id)
+	if err := s.saveTimers(); err != nil {
+		return Timer{}, err
+	}
+	return *t, nil
+}
+
+// every pending timer ordered by when it fires
+func (s *Scheduler) Timers() []Timer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []Timer{}
+	for _, t := range s.timers {
+		list = append(list, *t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].At.Before(list[j].At)
+	})
+	return list
+}
+
+// start the clock for the timer, the caller must hold the lock
+func (s *Scheduler) arm(t *Timer) {
+	s.timers[t.ID] = t
+	s.clocks[t.ID] = time.AfterFunc(time.Until(t.At), s.fire(t.ID))
+	if n, err := strconv.Atoi(t.ID); err == nil && n > s.lastTimerID {
+		s.lastTimerID = n
+	}
+}
+
+// stop the clock for the timer, the caller must hold the lock
+func (s *Scheduler) disarm(id string) {
+	if c, ok := s.clocks[id]; ok {
+		c.Stop()
+	}
+	delete(s.clocks, id)
+	delete(s.timers, id)
+}
+
+// persist the pending timers, the caller must hold the lock
+func (s *Scheduler) saveTimers() error {
+	if s.timerPath == "" {
+		return nil
+	}
+	list := []Timer{}
+	for _, t := range s.timers {
+		list = append(list, *t)
+	}
+	return store.Save(s.timerPath, list)
+}
+
+// the func run when the timer with id is due, it's removed before the job
+// runs so that it fires at most once
+func (s *Scheduler) fire(id string) func() {
+	return func() {
+		s.mu.Lock()
+		t, ok := s.timers[id]
+		var c Timer
+		if ok {
+			c = *t
+			s.disarm(id)
+			if err := s.saveTimers(); err != nil {
+				s.logger.Printf("unable to persist timers cause: %v", err)
+			}
+		}
+		s.mu.Unlock()
+		if !ok {
+			return
+		}
+		s.logger.Printf("firing timer: %s due: %s", c.ID, c.At)
+		s.timerJob(c)
+	}
+}